@@ -2,10 +2,13 @@
 
 (function() {
   let Types = {
+    ALERT_MESSAGE: "service_alert_message",
     LOG_MESSAGE: "service_log_message",
+    PROCESSES_MESSAGE: "service_processes_message",
     PROJECT_LIST: "project_list",
     PROJECT_UPDATE: "project_update_message",
     PROJECT_REMOVAL: "project_removal_message",
+    STATS_MESSAGE: "service_stats_message",
     STATUS_MESSAGE: "service_status_message"
   };
 
@@ -146,13 +149,23 @@
      * from the server
      */
     function handleDashboardCRUD(currData, dash) {
-      if (currData.type == Types.LOG_MESSAGE) {
+      if (currData.type == Types.ALERT_MESSAGE) {
+        orch.MessageFeed.error(`<strong>${orch.utilities.escapeHTML(currData.name)}</strong> raised the alert ` +
+          `<strong>${orch.utilities.escapeHTML(currData.alert)}</strong>: ` +
+          orch.utilities.escapeHTML(currData.line), 15000);
+      } else if (currData.type == Types.LOG_MESSAGE) {
         if (dash.services()[currData.id]) {
           dash.services()[currData.id].appendLine(currData.content);
         }
       } else if (currData.type == Types.STATUS_MESSAGE) {
         if (dash.services()[currData.id])
           dash.services()[currData.id].status(currData.status);
+      } else if (currData.type == Types.PROCESSES_MESSAGE) {
+        if (dash.services()[currData.id])
+          dash.services()[currData.id].processes(currData.processes);
+      } else if (currData.type == Types.STATS_MESSAGE) {
+        if (dash.services()[currData.id])
+          dash.services()[currData.id].stats(currData.stats);
       } else if (currData.type == Types.PROJECT_UPDATE) {
         dash.update(currData);
       } else if (currData.type == Types.PROJECT_REMOVAL) {
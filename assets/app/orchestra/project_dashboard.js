@@ -2,12 +2,26 @@
 
 (function() {
   let ServiceStatus = {
+      CRASH_LOOP: "crash_loop",
       DEAD: "dead",
+      DEGRADED: "degraded",
       FAILED: "failed",
+      HEALTHY: "healthy",
       INDETERMINATE: "indeterminate",
+      OOM_KILLED: "oom_killed",
+      PENDING: "pending",
+      RESTARTING: "restarting",
       RUNNING: "running",
-      STOPPED: "stopped"
+      SCHEDULED: "scheduled",
+      STARTING: "starting",
+      STOPPED: "stopped",
+      SUCCEEDED: "succeeded",
+      UNHEALTHY: "unhealthy",
+      WAITING: "waiting"
     },
+    // ACTIVE_STATUSES are the statuses where the service has a live process
+    // or schedule
+    ACTIVE_STATUSES = ["degraded", "healthy", "running", "scheduled", "starting", "unhealthy"],
     COLLAPSED = "collapsed",
     DISABLED = "disabled";
 
@@ -46,10 +60,12 @@
     function buildButtons() {
       let play = buildButton("btn-play", "glyphicon-play", "Start " + project.name),
         stop = buildButton("btn-stop", "glyphicon-stop", "Stop " + project.name),
+        restart = buildButton("btn-restart", "glyphicon-repeat", "Restart " + project.name),
         settings = buildSettings(),
         container = $("<div class='btn-container'/>");
 
       domButtons.play = play;
+      domButtons.restart = restart;
       domButtons.settings = settings;
       domButtons.stop = stop;
 
@@ -79,7 +95,20 @@
         }
       });
 
-      container.append([play, stop, settings]);
+      restart.click(function() {
+        if (restart.attr(DISABLED) == null) {
+          restart.attr(DISABLED, DISABLED);
+
+          socket.write({
+            data: [{
+              project_id: project.id,
+              type: "restart"
+            }]
+          });
+        }
+      });
+
+      container.append([play, restart, stop, settings]);
       return container;
     }
 
@@ -170,6 +199,7 @@
     function refreshButtons() {
       let runningServices = running()
       if (runningServices > 0) {
+        domButtons.restart.attr(DISABLED, null);
         domButtons.stop.attr(DISABLED, null);
         if (!settingsButtons.configure.hasClass(DISABLED))
           settingsButtons.configure.addClass(DISABLED);
@@ -192,6 +222,7 @@
         status = ServiceStatus.STOPPED;
         domElement.addClass(status);
         domButtons.play.attr(DISABLED, null);
+        domButtons.restart.attr(DISABLED, DISABLED);
         domButtons.stop.attr(DISABLED, DISABLED);
       }
     }
@@ -200,7 +231,7 @@
       let count = 0,
         keys = Object.keys(services);
       for (let i=0; i<keys.length; ++i) {
-        if (services[keys[i]].status() == ServiceStatus.RUNNING) {
+        if (ACTIVE_STATUSES.indexOf(services[keys[i]].status()) != -1) {
           count++;
         } else {
           console.log("Service " + services[keys[i]].name + " status was " + services[keys[i]].status());
@@ -216,7 +247,8 @@
     self.addService = function(service) {
       let serviceDash;
 
-      if (service.type == "runnable_service_configuration")
+      if (service.type == "runnable_service_configuration" || service.type == "scheduled_service_configuration" ||
+          service.type == "task_service_configuration")
         serviceDash = new orch.dashboard.ServiceDashboard(self, service);
       else if (service.type == "mockery_service_configuration")
         serviceDash = new mockery.dashboard.MockeryDashboard(self, service);
@@ -230,6 +262,33 @@
 
         // Now set the status so the buttons are configured correctly
         serviceDash.status(ServiceStatus.STOPPED);
+
+        if (service.type == "runnable_service_configuration" && !service.replica_of)
+          syncReplicas(service, service.replicas || 1);
+      }
+    }
+
+    /**
+     * syncReplicas makes sure there's a dashboard for each replica of the
+     * service past the first, which is shown on the service's own dashboard
+     * @param {service} service the service whose replicas to show
+     * @param {int}     count   how many replicas there are
+     */
+    function syncReplicas(service, count) {
+      for (let i=1; i<count || services[`${service.id}-${i}`]; ++i) {
+        let id = `${service.id}-${i}`;
+
+        if (i >= count) {
+          services[id].destroy();
+          delete services[id];
+        } else if (!services[id]) {
+          self.addService(Object.assign({}, service, {
+            id: id,
+            name: `${service.name} #${i}`,
+            replica_of: service.id,
+            replicas: 1
+          }));
+        }
       }
     }
 
@@ -366,8 +425,11 @@
      * configuration settings
      */
     self.update = function(projConfig) {
-      // Keep track of which services we've seen
-      let skippedServices = Object.keys(services);
+      // Keep track of which services we've seen, leaving out the replicas
+      // since they come and go along with their service
+      let skippedServices = Object.keys(services).filter(function(id) {
+        return !services[id].service || !services[id].service().replica_of;
+      });
 
       if (projConfig.project.name != null) {
         project.name = projConfig.project.name;
@@ -382,6 +444,9 @@
         if (services[updatedService.id]) {
           services[updatedService.id].update(updatedService);
           skippedServices.splice(skippedServices.indexOf(updatedService.id), 1);
+
+          if (updatedService.type == "runnable_service_configuration")
+            syncReplicas(updatedService, updatedService.replicas || 1);
         } else {
           // Unknown service, means we got a new one!
           self.addService(updatedService);
@@ -392,6 +457,7 @@
       // Clean up any services that weren't seen -- this means that
       // they were removed
       for (let i=0; i<skippedServices.length; ++i) {
+        syncReplicas({id: skippedServices[i]}, 1);
         services[skippedServices[i]].destroy();
         delete services[skippedServices[i]];
 
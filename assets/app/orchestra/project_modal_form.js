@@ -45,7 +45,9 @@
     for (let i=0; i<project.services.length; ++i) {
       let serviceBlock;
 
-      if (project.services[i].type == "runnable_service_configuration")
+      if (project.services[i].type == "runnable_service_configuration" ||
+          project.services[i].type == "scheduled_service_configuration" ||
+          project.services[i].type == "task_service_configuration")
         serviceBlock = new orch.modal.ServiceModalForm(project.services[i]);
       else if(project.services[i].type == "mockery_service_configuration")
         serviceBlock = new mockery.modal.MockeryModalForm(project.services[i]);
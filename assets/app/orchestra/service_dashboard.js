@@ -2,12 +2,26 @@
 
 (function() {
   let ServiceStatus = {
+      CRASH_LOOP: "crash_loop",
       DEAD: "dead",
+      DEGRADED: "degraded",
       FAILED: "failed",
+      HEALTHY: "healthy",
       INDETERMINATE: "indeterminate",
+      OOM_KILLED: "oom_killed",
+      PENDING: "pending",
+      RESTARTING: "restarting",
       RUNNING: "running",
-      STOPPED: "stopped"
+      SCHEDULED: "scheduled",
+      STARTING: "starting",
+      STOPPED: "stopped",
+      SUCCEEDED: "succeeded",
+      UNHEALTHY: "unhealthy",
+      WAITING: "waiting"
     },
+    // ACTIVE_STATUSES are the statuses where the service has a live process
+    // or schedule
+    ACTIVE_STATUSES = ["degraded", "healthy", "running", "scheduled", "starting", "unhealthy"],
     COLLAPSED = "collapsed",
     DISABLED = "disabled";
 
@@ -24,7 +38,10 @@
         domContents,
         domElement,
         domHeader,
+        domInput,
         domMinimized,
+        domProcesses,
+        domStats,
         domTitle,
         dropdown,
         isScrolling = false,
@@ -45,6 +62,7 @@
       function buildButtons() {
         let play = buildButton("btn-play", "glyphicon-play", "Start " + service.name),
           stop = buildButton("btn-stop", "glyphicon-stop", "Stop " + service.name),
+          restart = buildButton("btn-restart", "glyphicon-repeat", "Restart " + service.name),
           settings = buildSettings(),
           expand = buildButton("btn-expand", "glyphicon-resize-full", "Expand Dashboard"),
           contract = buildButton("btn-contract", "glyphicon-resize-small", "Shrink Dashboard"),
@@ -53,6 +71,7 @@
         domButtons.contract = contract;
         domButtons.expand = expand;
         domButtons.play = play;
+        domButtons.restart = restart;
         domButtons.settings = settings;
         domButtons.stop = stop;
 
@@ -84,6 +103,20 @@
           }
         });
 
+        restart.click(function() {
+          if (restart.attr(DISABLED) == null) {
+            restart.attr(DISABLED, DISABLED);
+
+            parent.socket().write({
+              data: [{
+                project_id: parent.project().id,
+                service_id: service.id,
+                type: "restart"
+              }]
+            });
+          }
+        });
+
         expand.click(function(evt) {
           if (flexAmount < 4) {
             flexAmount++;
@@ -114,7 +147,7 @@
           }
         });
 
-        container.append([play, stop, settings, expand, contract]);
+        container.append([play, restart, stop, settings, expand, contract]);
 
         return container;
       }
@@ -129,17 +162,75 @@
           "</div>"),
           configure = $("<li><a href='javascript:;'>Configure Service</a></li>"),
           changeBranch = $("<li class='disabled'><a href='javascript:;'>Change Branch</a></li>"),
+          scale = $("<li><a href='javascript:;'>Scale Replicas</a></li>"),
+          signal = $("<li class='disabled'><a href='javascript:;'>Send Signal</a></li>"),
+          processes = $("<li class='disabled'><a href='javascript:;'>Show Processes</a></li>"),
           clear = $("<li><a href='javascript:;'>Clear Log</a></li>");
 
         dropdown = settings.children(".dropdown-menu");
-        dropdown.append([configure, changeBranch, clear]);
+        dropdown.append([configure, changeBranch]);
         self.settingsButtons.configure = configure;
         self.settingsButtons.changeBranch = changeBranch;
         self.settingsButtons.clear = clear;
 
+        // Replicas are configured and scaled through their service
+        if (service.type == "runnable_service_configuration" && !service.replica_of) {
+          dropdown.append(scale);
+          self.settingsButtons.scale = scale;
+        }
+
+        // Only services with a process of their own can be sent signals
+        if (service.type != "mockery_service_configuration") {
+          dropdown.append([signal, processes]);
+          self.settingsButtons.signal = signal;
+          self.settingsButtons.processes = processes;
+        }
+
+        dropdown.append(clear);
+
         // Now setup the button actions
         configure.click(self.onConfigureClick);
 
+        scale.click(function() {
+          let replicas = parseInt(prompt("How many replicas of " + service.name + "?", service.replicas || 1));
+
+          if (replicas > 0) {
+            parent.socket().write({
+              data: [{
+                data: [replicas],
+                project_id: parent.project().id,
+                service_id: service.id,
+                type: "scale"
+              }]
+            });
+          }
+        });
+
+        signal.click(function() {
+          if (signal.hasClass(DISABLED)) {
+            return;
+          }
+
+          let name = prompt("Which signal should be sent to " + service.name + "?", "SIGHUP");
+
+          if (name) {
+            parent.socket().write({
+              data: [{
+                data: [name.trim()],
+                project_id: parent.project().id,
+                service_id: service.id,
+                type: "signal"
+              }]
+            });
+          }
+        });
+
+        processes.click(function() {
+          if (!processes.hasClass(DISABLED)) {
+            requestProcesses();
+          }
+        });
+
         clear.click(function() {
           if (!clear.hasClass(DISABLED)) {
             self.clear();
@@ -149,6 +240,73 @@
         return settings;
       }
 
+      /**
+       * buildProcessList builds the nested list of the given processes, with
+       * a way to kill each one that isn't the leader of the service
+       */
+      function buildProcessList(processes, isRoot) {
+        let list = $("<ul/>");
+
+        for (let i=0; i<processes.length; ++i) {
+          let proc = processes[i],
+            item = $("<li/>"),
+            line = $("<div class='process-line'/>");
+
+          line.append($("<span class='process-info'/>").text(`${proc.pid} ${proc.state} ` +
+            `${(proc.rss / 1048576).toFixed(1)} MB ` +
+            `${new Date(proc.started_at).toLocaleTimeString()}`));
+          line.append($("<span class='process-command'/>").text(proc.command));
+
+          if (!isRoot) {
+            let kill = $("<a href='javascript:;' title='Send SIGTERM'>kill</a>"),
+              force = $("<a href='javascript:;' title='Send SIGKILL'>force</a>");
+
+            kill.click(function() { killProcess(proc.pid, false); });
+            force.click(function() { killProcess(proc.pid, true); });
+            line.append($("<span class='process-actions'/>").append([kill, force]));
+          }
+
+          item.append(line);
+          if (proc.children && proc.children.length > 0) {
+            item.append(buildProcessList(proc.children, false));
+          }
+
+          list.append(item);
+        }
+
+        return list;
+      }
+
+      /**
+       * killProcess asks for one of the service's processes to be killed, and
+       * refreshes the process list once it's had a moment to go down
+       */
+      function killProcess(pid, force) {
+        parent.socket().write({
+          data: [{
+            data: [pid, force],
+            project_id: parent.project().id,
+            service_id: service.id,
+            type: "kill"
+          }]
+        });
+
+        setTimeout(requestProcesses, 500);
+      }
+
+      /**
+       * requestProcesses asks the server for the service's process tree
+       */
+      function requestProcesses() {
+        parent.socket().write({
+          data: [{
+            project_id: parent.project().id,
+            service_id: service.id,
+            type: "processes"
+          }]
+        });
+      }
+
       function scrollToBottom() {
         if (tailing && !isScrolling) {
           isScrolling = true;
@@ -288,7 +446,8 @@
       }
 
       self.onConfigureClick = function() {
-        if (!self.settingsButtons.configure.hasClass(DISABLED) && self.status() != ServiceStatus.RUNNING) {
+        if (!self.settingsButtons.configure.hasClass(DISABLED) && ACTIVE_STATUSES.indexOf(self.status()) == -1 &&
+            !service.replica_of) {
           let modal = orch.globals.MainModal,
             currentForm;
 
@@ -307,6 +466,7 @@
           domElement = $(`<div class='${self.dashboardClass()}'/>`);
           domHeader = $("<div class='service-dashboard-header header'/>");
           domTitle = $(`<h3>${service.name}</h3>`);
+          domStats = $("<div class='service-stats'/>");
 
           domElement.click(function() {
             if (orch.globals.selectedService &&
@@ -317,7 +477,7 @@
             orch.globals.selectedService.domElement().addClass("selected");
           });
 
-          domHeader.append([buildButtons(), domTitle]);
+          domHeader.append([buildButtons(), domTitle, domStats]);
           domHeader.dblclick(function() {
             if (parent.visibleServices().length > 1) {
               if (flexAmount <= 1) {
@@ -333,6 +493,7 @@
             }
           });
 
+          domProcesses = $("<div class='service-processes hidden'/>");
           domContents = $("<div class='service-logs empty'/>");
           domContents.bind('mousewheel DOMMouseScroll', function(e) {
             let scrollTo = null;
@@ -363,7 +524,25 @@
               }
             }
           });
-          domElement.append([domHeader, domContents]);
+          domInput = $("<input type='text' class='service-input' placeholder='Send input to " +
+            service.name + "...'/>");
+          domInput.keydown(function(e) {
+            // Enter sends the current line to the service's stdin
+            if (e.which == 13) {
+              parent.socket().write({
+                data: [{
+                  data: [domInput.val()],
+                  project_id: parent.project().id,
+                  service_id: service.id,
+                  type: "input"
+                }]
+              });
+
+              domInput.val("");
+            }
+          });
+
+          domElement.append([domHeader, domProcesses, domContents, domInput]);
         }
 
         return domElement;
@@ -394,6 +573,49 @@
         return service;
       }
 
+      /**
+       * Shows the process tree of the service, or hides it when given null
+       */
+      self.processes = function(processes) {
+        domProcesses.empty();
+
+        if (processes == null) {
+          domProcesses.addClass("hidden");
+          return;
+        }
+
+        let refresh = $("<a href='javascript:;'>refresh</a>"),
+          close = $("<a href='javascript:;'>close</a>"),
+          header = $("<div class='process-header'><strong>Processes</strong></div>");
+
+        refresh.click(requestProcesses);
+        close.click(function() { self.processes(null); });
+        header.append($("<span class='process-actions'/>").append([refresh, close]));
+
+        domProcesses.append(header);
+        if (processes.length == 0) {
+          domProcesses.append($("<div class='process-line'/>").text("No processes running"));
+        } else {
+          domProcesses.append(buildProcessList(processes, true));
+        }
+
+        domProcesses.removeClass("hidden");
+      }
+
+      /**
+       * Shows the latest resource usage sample for the service
+       */
+      self.stats = function(stats) {
+        if (stats == null) {
+          domStats.text("");
+          return;
+        }
+
+        domStats.text(`CPU ${stats.cpu_percent.toFixed(1)}% · ` +
+          `RSS ${(stats.rss / 1048576).toFixed(1)} MB · ` +
+          `${stats.threads} threads · ${stats.file_descriptors} fds`);
+      }
+
       /**
        * Getter/setter for status
        */
@@ -402,7 +624,7 @@
           domElement.removeClass(status);
           domMinimized.removeClass(status);
 
-          if (status == ServiceStatus.RUNNING && arguments[0] != ServiceStatus.RUNNING) {
+          if (ACTIVE_STATUSES.indexOf(status) != -1 && ACTIVE_STATUSES.indexOf(arguments[0]) == -1) {
             domContents.append($("<hr />"));
           }
 
@@ -410,15 +632,29 @@
           domElement.addClass(status);
           domMinimized.addClass(status);
 
-          if (status == ServiceStatus.RUNNING) {
+          if (ACTIVE_STATUSES.indexOf(status) != -1) {
             domButtons.play.attr(DISABLED, DISABLED);
+            domButtons.restart.attr(DISABLED, null);
             domButtons.stop.attr(DISABLED, null);
             self.settingsButtons.configure.addClass(DISABLED);
 
+            if (self.settingsButtons.signal) {
+              self.settingsButtons.signal.removeClass(DISABLED);
+              self.settingsButtons.processes.removeClass(DISABLED);
+            }
           } else {
             domButtons.play.attr(DISABLED, null);
+            domButtons.restart.attr(DISABLED, DISABLED);
             domButtons.stop.attr(DISABLED, DISABLED);
             self.settingsButtons.configure.removeClass(DISABLED);
+            self.stats(null);
+
+            if (self.settingsButtons.signal) {
+              self.settingsButtons.signal.addClass(DISABLED);
+              self.settingsButtons.processes.addClass(DISABLED);
+            }
+
+            self.processes(null);
           }
 
           parent.refreshButtons();
@@ -434,11 +670,14 @@
       self.update = function(serviceConfig) {
         service.name = serviceConfig.name;
         service.description = serviceConfig.description;
+        service.repository = serviceConfig.repository;
         service.branch = serviceConfig.branch;
         service.delay_after = serviceConfig.delay_after;
         service.delay_before = serviceConfig.delay_before;
+        service.depends_on = serviceConfig.depends_on;
         service.working_dir = serviceConfig.working_dir;
         service.commands = serviceConfig.commands;
+        service.replicas = serviceConfig.replicas;
 
         domTitle.html(service.name);
       }
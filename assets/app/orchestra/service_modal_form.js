@@ -7,11 +7,20 @@
       style = (visible == false) ? `${defaultClasses} collapsed` : defaultClasses,
       nameField = new orch.ui.TextField("name", "Name", service.name),
       descField = new orch.ui.TextField("description", "Description", service.description),
+      repositoryField = new orch.ui.TextField("repository", "Repository", service.repository || ""),
       branchField = new orch.ui.TextField("branch", "Branch", service.branch),
       delayAfterField = new orch.ui.TextField("delay_after", "Delay After", "" + service.delay_after),
       delayBeforeField = new orch.ui.TextField("delay_before", "Delay Before", "" + service.delay_before),
       workingField = new orch.ui.TextField("working_dir", "Working Dir", service.working_dir),
-      commandsField = new orch.ui.CodeField("commands", "Commands", service.commands.join("\n"));
+      dependsField = new orch.ui.TextField("depends_on", "Depends On", (service.depends_on || []).join(", ")),
+      commandsField = new orch.ui.CodeField("commands", "Commands", service.commands.join("\n")),
+      scheduled = service.type == "scheduled_service_configuration",
+      task = service.type == "task_service_configuration",
+      replicated = !scheduled && !task,
+      replicasField = new orch.ui.TextField("replicas", "Replicas", "" + (service.replicas || 1)),
+      cronField = new orch.ui.TextField("cron", "Cron", service.cron || ""),
+      intervalField = new orch.ui.TextField("interval", "Interval", "" + (service.interval || "")),
+      overlapField = new orch.ui.TextField("overlap", "Overlap", service.overlap || "skip");
 
     // extend ModalForm
     orch.modal.ModalForm.call(self, style, title, isRoot);
@@ -21,12 +30,34 @@
       nameField,
       descField,
       workingField,
+      repositoryField,
       branchField,
       delayAfterField,
       delayBeforeField,
+      dependsField,
       commandsField
     );
 
+    if (replicated) {
+      self.addFields(replicasField);
+
+      replicasField.errorMessage("Enter a number of replicas between 1 and 64");
+      replicasField.validate = function() {
+        let replicas = parseInt(replicasField.value());
+        return replicas >= 1 && replicas <= 64;
+      }
+    }
+
+    if (scheduled) {
+      self.addFields(cronField, intervalField, overlapField);
+
+      intervalField.errorMessage("Enter either a cron expression or an interval in seconds");
+      intervalField.validate = function() {
+        let interval = intervalField.value().trim();
+        return (cronField.value().trim().length > 0) != (interval.length > 0 && parseInt(interval) > 0);
+      }
+    }
+
     // Setup validators and error messages
     nameField.errorMessage("The name you entered isn't valid");
     nameField.validate = function() {
@@ -80,13 +111,30 @@
       data.name = nameField.value();
       data.id = service.id;
       data.description = descField.value();
+      data.repository = repositoryField.value();
       data.branch = branchField.value();
       data.delay_after = parseInt(delayAfterField.value()) || 0;
       data.delay_before = parseInt(delayBeforeField.value()) || 0;
       data.working_dir = workingField.value();
-      data.type = "runnable_service_configuration";
+      data.depends_on = dependsField.value().split(",").map(function(dep) {
+        return dep.trim();
+      }).filter(function(dep) {
+        return dep.length > 0;
+      });
+      data.type = scheduled ? "scheduled_service_configuration" :
+        task ? "task_service_configuration" : "runnable_service_configuration";
       data.commands = commandsField.value();
 
+      if (replicated) {
+        data.replicas = parseInt(replicasField.value()) || 1;
+      }
+
+      if (scheduled) {
+        data.cron = cronField.value().trim();
+        data.interval = parseInt(intervalField.value()) || 0;
+        data.overlap = overlapField.value().trim();
+      }
+
       return data;
     }
   }
@@ -9,6 +9,13 @@
   function OrchestraUtilities() {
     let self = this;
 
+    /**
+     * escapeHTML escapes the given text so it can be safely put into markup
+     */
+    function escapeHTML(text) {
+      return $("<div/>").text(text).html();
+    }
+
     /**
      * getHost returns the full host path, respecting the original protocol and
      * port of the initial request
@@ -49,6 +56,7 @@
       return null;
     }
 
+    self.escapeHTML = escapeHTML;
     self.getHost = getHost;
     self.onAnimationEnd = onAnimationEnd;
     self.whichTransitionEvent = whichTransitionEvent;
@@ -6,11 +6,19 @@ import (
 
 // Command constants
 const (
+	CommandHistory          = "history"
+	CommandInput            = "input"
+	CommandKill             = "kill"
 	CommandMockeryTestStart = "mockery_test_start"
 	CommandListProjects     = "list_projects"
 	CommandNew              = "new"
+	CommandProcesses        = "processes"
 	CommandRemoveProject    = "remove_project"
+	CommandResize           = "resize"
 	CommandRestart          = "restart"
+	CommandRun              = "run"
+	CommandScale            = "scale"
+	CommandSignal           = "signal"
 	CommandStart            = "start"
 	CommandStop             = "stop"
 	CommandUpdate           = "update"
@@ -23,14 +31,32 @@ func processCommand(entry IncomingSocketCommand, user *User) {
 	switch entry.Type {
 	// case CommandNew:
 	// 	performNew(entry)
+	case CommandHistory:
+		performHistory(entry, user)
+	case CommandInput:
+		performInput(entry)
+	case CommandKill:
+		performKill(entry)
 	case CommandListProjects:
 		performListProjects(entry, user)
+	case CommandProcesses:
+		performProcesses(entry, user)
 	case CommandStart:
 		performStart(entry)
 	case CommandStop:
 		performStop(entry)
 	case CommandRemoveProject:
 		performRemoveProject(entry)
+	case CommandResize:
+		performResize(entry)
+	case CommandRestart:
+		performRestart(entry)
+	case CommandRun:
+		performRun(entry)
+	case CommandScale:
+		performScale(entry)
+	case CommandSignal:
+		performSignal(entry)
 	case CommandSetActiveConfig:
 		performSetActiveConfig(entry)
 	case CommandUpdate:
@@ -40,9 +66,215 @@ func processCommand(entry IncomingSocketCommand, user *User) {
 	}
 }
 
+// performHistory will send the run history of a given service back to the
+// user that asked for it
+func performHistory(entry IncomingSocketCommand, user *User) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, s := range project.Services {
+			service, ok := s.(ServiceInterface)
+			if !ok || !service.IsMatch(entry.ServiceID) {
+				continue
+			}
+
+			matched = true
+			if historical, ok := service.(HistoricalService); ok {
+				user.WriteHistoryMessage(HistoryStatusListed, historical.GetHistory().GetRuns(), service)
+			} else {
+				Error.Println("Cannot send the history of", service.GetName()+":", ErrorServiceNoHistory)
+			}
+			break
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
+// performProcesses will send the process tree of a given service back to
+// the user that asked for it
+func performProcesses(entry IncomingSocketCommand, user *User) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, s := range project.Services {
+			service, ok := s.(ServiceInterface)
+			if replica := matchReplica(s, entry.ServiceID); replica != nil {
+				service, ok = replica, true
+			}
+
+			if !ok || !service.IsMatch(entry.ServiceID) {
+				continue
+			}
+
+			matched = true
+			inspectable, ok := service.(InspectableService)
+			if !ok {
+				Error.Println("Cannot list the processes of", service.GetName()+":", ErrorServiceNotInspectable)
+				break
+			}
+
+			processes, err := inspectable.GetProcesses()
+			if err != nil {
+				Error.Println("Cannot list the processes of", service.GetName()+":", err)
+				processes = []*ProcessNode{}
+			}
+
+			user.WriteProcessesMessage(processes, service)
+			break
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
+// performKill will attempt to kill the process with the PID in the first
+// data entry, which has to belong to a given service. A second, truthy data
+// entry kills it outright rather than asking it to exit
+func performKill(entry IncomingSocketCommand) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, service := range project.Services {
+			if performAction(entry, service, project, CommandKill) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
+// performInput will attempt to send the first data entry to the stdin of a
+// given service. A second, truthy data entry sends the input raw rather than
+// as a line
+func performInput(entry IncomingSocketCommand) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, service := range project.Services {
+			if performAction(entry, service, project, CommandInput) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
+// performResize will attempt to resize the terminal of a given service to
+// the columns and rows in the first two data entries
+func performResize(entry IncomingSocketCommand) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, service := range project.Services {
+			if performAction(entry, service, project, CommandResize) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
+// performRun will attempt to run a given task service again
+func performRun(entry IncomingSocketCommand) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, service := range project.Services {
+			if performAction(entry, service, project, CommandRun) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
+// performScale will attempt to scale a given service to the replica count
+// in the first data entry
+func performScale(entry IncomingSocketCommand) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, service := range project.Services {
+			if performAction(entry, service, project, CommandScale) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
+// performSignal will attempt to send the signal named in the first data
+// entry to a given service. A second, truthy data entry sends it to the
+// leader of the process group only
+func performSignal(entry IncomingSocketCommand) {
+	project := findProject(entry)
+
+	if project != nil {
+		matched := false
+		for _, service := range project.Services {
+			if performAction(entry, service, project, CommandSignal) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
 // performListProjects will list all of the known projects to the consumer
 func performListProjects(entry IncomingSocketCommand, user *User) {
-	user.WriteJSON("project_list", Config.Projects)
+	user.WriteJSON("project_list", buildProjectsPayload())
 	dumpExistingData(user)
 }
 
@@ -103,7 +335,10 @@ func performStart(entry IncomingSocketCommand) {
 	if project != nil {
 		if entry.ServiceID == "" {
 			Debug.Println("Starting project", project.Name)
-			project.Start()
+
+			// Starting a project can block on readiness probes, so don't hold
+			// up the socket while it happens
+			go project.Start(entry.User)
 		} else {
 			matched := false
 			for _, service := range project.Services {
@@ -132,7 +367,7 @@ func performStop(entry IncomingSocketCommand) {
 		for _, service := range project.Services {
 			if entry.ServiceID == "" {
 				Debug.Println("Stopping project", project.Name)
-				project.Stop()
+				go project.Stop()
 				matched = true
 				break
 			} else {
@@ -152,6 +387,37 @@ func performStop(entry IncomingSocketCommand) {
 	}
 }
 
+// performRestart will attempt to restart a given service, or the whole
+// project when no service is given
+func performRestart(entry IncomingSocketCommand) {
+	project := findProject(entry)
+
+	if project != nil {
+		if entry.ServiceID == "" {
+			Debug.Println("Restarting project", project.Name)
+
+			// Restarting waits out the stop grace periods and the readiness
+			// probes, so don't hold up the socket while it happens
+			go project.Restart(entry.User)
+			return
+		}
+
+		matched := false
+		for _, service := range project.Services {
+			if performAction(entry, service, project, CommandRestart) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			Error.Println("Could not find a matching service for", entry)
+		}
+	} else {
+		Error.Println("Could not find matching project", entry.ProjectID)
+	}
+}
+
 // performRemoveProject will attempt to start a given service
 func performRemoveProject(entry IncomingSocketCommand) {
 	project := findProject(entry)
@@ -200,16 +466,42 @@ func performUpdate(entry IncomingSocketCommand) {
 func performAction(entry IncomingSocketCommand, srvc interface{}, project *ProjectConfiguration, action string) bool {
 	matched := false
 
+	// Replicas aren't services of the project in their own right, so they're
+	// found through the service they're a replica of
+	if replica := matchReplica(srvc, entry.ServiceID); replica != nil {
+		srvc = replica
+	}
+
 	if service, ok := srvc.(ServiceInterface); ok {
 		for _, iface := range ServiceInterfaces {
 			if iface.Accept(service) && service.IsMatch(entry.ServiceID) {
 				switch action {
 				case CommandStart:
-					service.Start()
+					// Starting runs the start hooks, and may have to clone the
+					// repository first, so don't hold up the socket for it
+					requestStart(service, entry.User)
+					go service.Start()
 				case CommandStop:
-					service.Stop()
+					// Stopping waits out the service's grace period, so don't
+					// hold up the socket while it happens
+					go service.Stop()
+				case CommandRun:
+					runTask(service, entry.User)
+				case CommandRestart:
+					// Restarting waits out the service's grace period too
+					go restartService(service, entry.User)
 				case CommandUpdate:
-					service.Update(entry.Data[0].(map[string]interface{}))
+					updateService(service, entry.Data[0].(map[string]interface{}))
+				case CommandInput:
+					writeServiceInput(service, entry.Data)
+				case CommandKill:
+					killServiceProcess(service, entry.Data)
+				case CommandResize:
+					resizeService(service, entry.Data)
+				case CommandScale:
+					scaleService(service, entry.Data)
+				case CommandSignal:
+					signalService(service, entry.Data)
 				}
 
 				matched = true
@@ -220,3 +512,161 @@ func performAction(entry IncomingSocketCommand, srvc interface{}, project *Proje
 
 	return matched
 }
+
+// updateService updates the service with the given config, as long as that
+// doesn't leave two services of the project fixing the same port
+func updateService(service ServiceInterface, config map[string]interface{}) {
+	err := service.GetProject().validateServiceUpdate(service, config)
+	if err == nil {
+		err = service.Update(config)
+	}
+
+	if err != nil {
+		Error.Println("Cannot update", service.GetName()+":", err)
+	}
+}
+
+// writeServiceInput unpacks the input command data and sends it along to
+// the service
+func writeServiceInput(service ServiceInterface, data []interface{}) {
+	interactive, ok := service.(InteractiveService)
+	if !ok {
+		Error.Println("Cannot send input to", service.GetName()+":", ErrorServiceNotInteractive)
+		return
+	}
+
+	if len(data) == 0 {
+		Error.Println("No input was given for", service.GetName())
+		return
+	}
+
+	input, _ := data[0].(string)
+	if len(data) < 2 || data[1] != true {
+		input = input + "\n"
+	}
+
+	if err := interactive.WriteInput([]byte(input)); err != nil {
+		Error.Println("Cannot send input to", service.GetName()+":", err)
+	}
+}
+
+// resizeService unpacks the resize command data and resizes the service's
+// terminal
+func resizeService(service ServiceInterface, data []interface{}) {
+	resizable, ok := service.(ResizableService)
+	if !ok {
+		Error.Println("Cannot resize", service.GetName()+":", ErrorServiceNotTerminal)
+		return
+	}
+
+	if len(data) < 2 {
+		Error.Println("No terminal size was given for", service.GetName())
+		return
+	}
+
+	// JSON numbers always come through as floats
+	cols, _ := data[0].(float64)
+	rows, _ := data[1].(float64)
+
+	if err := resizable.Resize(int(cols), int(rows)); err != nil {
+		Error.Println("Cannot resize", service.GetName()+":", err)
+	}
+}
+
+// runTask runs the service again on behalf of the given user, as long as
+// it's a task
+func runTask(service ServiceInterface, user string) {
+	task, ok := service.(TaskService)
+	if !ok {
+		Error.Println("Cannot run", service.GetName()+":", ErrorServiceNotTask)
+		return
+	}
+
+	requestStart(service, user)
+
+	if err := task.Run(); err != nil {
+		Error.Println("Cannot run", service.GetName()+":", err)
+	}
+}
+
+// scaleService unpacks the scale command data and scales the service to it,
+// letting everyone know about the new replica count
+func scaleService(service ServiceInterface, data []interface{}) {
+	scalable, ok := service.(ScalableService)
+	if !ok {
+		Error.Println("Cannot scale", service.GetName()+":", ErrorServiceNotScalable)
+		return
+	}
+
+	if len(data) == 0 {
+		Error.Println("No replica count was given for", service.GetName())
+		return
+	}
+
+	// JSON numbers always come through as floats
+	replicas, _ := data[0].(float64)
+
+	if err := scalable.Scale(int(replicas)); err != nil {
+		Error.Println("Cannot scale", service.GetName()+":", err)
+		return
+	}
+
+	broadcastProjectUpdate(service.GetProject())
+}
+
+// signalService unpacks the signal command data and sends the signal along
+// to the service
+func signalService(service ServiceInterface, data []interface{}) {
+	signalable, ok := service.(SignalableService)
+	if !ok {
+		Error.Println("Cannot signal", service.GetName()+":", ErrorServiceNotSignalable)
+		return
+	}
+
+	if len(data) == 0 {
+		Error.Println("No signal was given for", service.GetName())
+		return
+	}
+
+	name, _ := data[0].(string)
+	leaderOnly := len(data) > 1 && data[1] == true
+
+	if err := signalable.Signal(name, leaderOnly); err != nil {
+		Error.Println("Cannot signal", service.GetName()+":", err)
+	}
+}
+
+// killServiceProcess unpacks the kill command data and kills the process
+// of the service it picks out
+func killServiceProcess(service ServiceInterface, data []interface{}) {
+	inspectable, ok := service.(InspectableService)
+	if !ok {
+		Error.Println("Cannot kill a process of", service.GetName()+":", ErrorServiceNotInspectable)
+		return
+	}
+
+	// Numbers come off the socket as floats
+	if len(data) == 0 {
+		Error.Println("No process was given to kill for", service.GetName())
+		return
+	}
+
+	pid, _ := data[0].(float64)
+	force := len(data) > 1 && data[1] == true
+
+	if err := inspectable.KillProcess(int(pid), force); err != nil {
+		Error.Println("Cannot kill a process of", service.GetName()+":", err)
+	}
+}
+
+// restartService restarts the service on behalf of the given user, falling
+// back on a plain stop and start for services with no processes to wait out
+func restartService(service ServiceInterface, user string) bool {
+	requestStart(service, user)
+	if restartable, ok := service.(RestartableService); ok {
+		return restartable.Restart()
+	}
+
+	service.Stop()
+	return service.Start()
+}
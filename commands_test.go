@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProcessCommandRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := &ProjectConfiguration{ID: "commands", Name: "commands"}
+	task := ServiceInterfaces[TypeTaskService].Create(map[string]interface{}{
+		"type":        TypeTaskService,
+		"name":        "task",
+		"working_dir": dir,
+		"commands":    []string{"true"},
+	}, project).(*TaskServiceConfiguration)
+	project.Services = []interface{}{task}
+
+	Config.Projects[project.ID] = project
+	defer delete(Config.Projects, project.ID)
+
+	processCommand(IncomingSocketCommand{
+		ProjectID: project.ID,
+		ServiceID: task.ID,
+		Type:      CommandRun,
+		User:      "tester",
+	}, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for task.GetState() != ServiceSucceeded && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if state := task.GetState(); state != ServiceSucceeded {
+		t.Fatalf("Expected the run command to run the task to success, it was %s", state)
+	}
+
+	// The run is recorded once its exit has been dealt with
+	for len(task.GetHistory().GetRuns()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	runs := task.GetHistory().GetRuns()
+	if len(runs) != 1 || runs[0].Trigger != TriggerManual || runs[0].User != "tester" {
+		t.Errorf("Expected a manual run by the tester to be recorded, got %+v", runs)
+	}
+}
@@ -18,13 +18,18 @@ type Configuration struct {
 
 // ServerConfiguration the configuration for the server
 type ServerConfiguration struct {
-	AcceptAddr      string `json:"accept_addr"`
-	AcceptAddrHTTPS string `json:"accept_addr_ssl"`
-	LogLevel        string `json:"log_level"`
-	Port            string `json:"port"`
-	PortHTTPS       string `json:"port_ssl"`
-	ShellExe        string `json:"shell_exe" default:"bash"`
-	HTTPSOnly       bool   `json:"https_only"`
+	AcceptAddr      string   `json:"accept_addr"`
+	AcceptAddrHTTPS string   `json:"accept_addr_ssl"`
+	CgroupRoot      string   `json:"cgroup_root,omitempty"`
+	LogLevel        string   `json:"log_level"`
+	Port            string   `json:"port"`
+	PortHTTPS       string   `json:"port_ssl"`
+	PortRangeEnd    int      `json:"port_range_end,omitempty"`
+	PortRangeStart  int      `json:"port_range_start,omitempty"`
+	ShellArgs       []string `json:"shell_args,omitempty"`
+	ShellExe        string   `json:"shell_exe" default:"bash"`
+	StatsInterval   int      `json:"stats_interval,omitempty"`
+	HTTPSOnly       bool     `json:"https_only"`
 }
 
 // ServiceConfiguration the service configuration
@@ -67,10 +72,18 @@ var (
 	// TypeMockeryService the mockery service type
 	TypeMockeryService = "mockery_service_configuration"
 
+	// TypeScheduledService the scheduled service type
+	TypeScheduledService = "scheduled_service_configuration"
+
+	// TypeTaskService the task service type
+	TypeTaskService = "task_service_configuration"
+
 	// ServiceInterfaces the set of possible service interfaces
 	ServiceInterfaces = map[string]ServiceInterface{
-		TypeMockeryService:  &MockeryServiceConfiguration{},
-		TypeRunnableService: &RunnableServiceConfiguration{},
+		TypeMockeryService:   &MockeryServiceConfiguration{},
+		TypeRunnableService:  &RunnableServiceConfiguration{},
+		TypeScheduledService: &ScheduledServiceConfiguration{},
+		TypeTaskService:      &TaskServiceConfiguration{},
 	}
 )
 
@@ -174,7 +187,13 @@ func loadConfiguration(configName string) *Configuration {
 	return &config
 }
 
+// getHomeDir returns the directory the orchestra home lives under, which is
+// the user's home unless it's overridden through the environment
 func getHomeDir() string {
+	if dir := os.Getenv(OrchestraHomeEnvVar); dir != "" {
+		return dir
+	}
+
 	dir := ""
 
 	usr, err := user.Current()
@@ -187,6 +206,15 @@ func getHomeDir() string {
 	return dir
 }
 
+// getServerShell returns the server wide shell executable and its arguments
+func getServerShell() (string, []string) {
+	if Config != nil && Config.Server.ShellExe != "" {
+		return Config.Server.ShellExe, Config.Server.ShellArgs
+	}
+
+	return DefaultConfiguration.Server.ShellExe, nil
+}
+
 // Save saves the current configuration out
 func (c *Configuration) Save() error {
 	data, err := json.Marshal(c)
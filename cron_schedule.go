@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// CronMacros the shorthand schedules that stand in for a full expression
+	CronMacros = map[string]string{
+		"@annually": "0 0 1 1 *",
+		"@daily":    "0 0 * * *",
+		"@hourly":   "0 * * * *",
+		"@midnight": "0 0 * * *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@yearly":   "0 0 1 1 *",
+	}
+
+	// CronMonthNames the names that can stand in for months
+	CronMonthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+
+	// CronWeekdayNames the names that can stand in for days of the week
+	CronWeekdayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+
+	// cronSearchLimit how far ahead a schedule is searched for its next time
+	// before giving up on it, which covers every leap day
+	cronSearchLimit = 5 * 366 * 24 * time.Hour
+)
+
+// CronSchedule a parsed five field cron expression, made up of the minute,
+// hour, day of the month, month and day of the week. Each field is a bit
+// set of the values it allows
+type CronSchedule struct {
+	DaysOfMonth uint64
+	DaysOfWeek  uint64
+	Hours       uint64
+	Minutes     uint64
+	Months      uint64
+
+	// AnyDay whether either day field was left as a wildcard, in which case
+	// a day only has to match the other one. Otherwise matching either will
+	// do, which is how cron has always done it
+	AnyDay bool
+}
+
+// ParseCronSchedule parses a standard cron expression. Fields can be
+// wildcards, values, ranges or lists of them, with an optional step, and
+// months and weekdays can be given by name
+func ParseCronSchedule(expression string) (*CronSchedule, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, ok := CronMacros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("The cron expression %q must have 5 fields", expression)
+	}
+
+	schedule := &CronSchedule{
+		AnyDay: strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")}
+
+	var err error
+	if schedule.Minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+
+	if schedule.Hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+
+	if schedule.DaysOfMonth, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+
+	if schedule.Months, err = parseCronField(fields[3], 1, 12, CronMonthNames); err != nil {
+		return nil, err
+	}
+
+	// Sunday is both 0 and 7
+	if schedule.DaysOfWeek, err = parseCronField(fields[4], 0, 7, CronWeekdayNames); err != nil {
+		return nil, err
+	}
+
+	if schedule.DaysOfWeek&(1<<7) != 0 {
+		schedule.DaysOfWeek |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseCronField parses a single field into the bit set of values it allows
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[slash+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("The cron field %q has an invalid step", field)
+			}
+
+			part = part[:slash]
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			if low, err = parseCronValue(bounds[0], names); err != nil {
+				return 0, fmt.Errorf("The cron field %q has an invalid value", field)
+			}
+
+			high = low
+			if len(bounds) == 2 {
+				if high, err = parseCronValue(bounds[1], names); err != nil {
+					return 0, fmt.Errorf("The cron field %q has an invalid value", field)
+				}
+			} else if step > 1 {
+				// As in "5/15", which runs from the value to the end
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("The cron field %q is out of range (%d-%d)", field, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+// parseCronValue parses a single value, which may be a name
+func parseCronValue(value string, names map[string]int) (int, error) {
+	if number, ok := names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// matchesDay tests whether the schedule runs on the day of the given time
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := c.DaysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.DaysOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if c.AnyDay {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first time after the given one that the schedule runs
+// at, or the zero time if it never does (as in the 31st of February)
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	// Skip ahead a month, day, hour or minute at a time until everything
+	// lines up
+	for t.Before(limit) {
+		if c.Months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.Hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.Minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// cronBits builds the bit set a field parses into
+func cronBits(values ...int) uint64 {
+	var bits uint64
+	for _, value := range values {
+		bits |= 1 << uint(value)
+	}
+
+	return bits
+}
+
+func TestParseCronField(t *testing.T) {
+	allMinutes := uint64(1<<60 - 1)
+
+	tests := []struct {
+		field string
+		min   int
+		max   int
+		names map[string]int
+		bits  uint64
+		valid bool
+	}{
+		{"*", 0, 59, nil, allMinutes, true},
+		{"5", 0, 59, nil, cronBits(5), true},
+		{"1,3,5", 0, 59, nil, cronBits(1, 3, 5), true},
+		{"1-5", 0, 59, nil, cronBits(1, 2, 3, 4, 5), true},
+		{"*/15", 0, 59, nil, cronBits(0, 15, 30, 45), true},
+		{"5/15", 0, 59, nil, cronBits(5, 20, 35, 50), true},
+		{"1-10/3", 1, 31, nil, cronBits(1, 4, 7, 10), true},
+		{"0-4,20-22/2", 0, 23, nil, cronBits(0, 1, 2, 3, 4, 20, 22), true},
+		{"jan-mar", 1, 12, CronMonthNames, cronBits(1, 2, 3), true},
+		{"Dec", 1, 12, CronMonthNames, cronBits(12), true},
+		{"MON-fri", 0, 7, CronWeekdayNames, cronBits(1, 2, 3, 4, 5), true},
+		{"60", 0, 59, nil, 0, false},
+		{"0", 1, 31, nil, 0, false},
+		{"5-1", 0, 59, nil, 0, false},
+		{"*/0", 0, 59, nil, 0, false},
+		{"*/x", 0, 59, nil, 0, false},
+		{"1-", 0, 59, nil, 0, false},
+		{"x", 0, 59, nil, 0, false},
+		{"", 0, 59, nil, 0, false},
+		{"1,,2", 0, 59, nil, 0, false},
+		{"jan", 0, 59, nil, 0, false},
+	}
+
+	for _, test := range tests {
+		bits, err := parseCronField(test.field, test.min, test.max, test.names)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("Expected %q to be rejected", test.field)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %s", test.field, err)
+		} else if bits != test.bits {
+			t.Errorf("Expected %q to parse into %b, got %b", test.field, test.bits, bits)
+		}
+	}
+}
+
+func TestParseCronSchedule(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "* * * * * *", "61 * * * *", "* 24 * * *", "* * 32 * *", "* * * 13 *", "* * * * 8", "@fortnightly"} {
+		if _, err := ParseCronSchedule(expression); err == nil {
+			t.Errorf("Expected %q to be rejected", expression)
+		}
+	}
+
+	schedule, err := ParseCronSchedule("@weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schedule.Minutes != cronBits(0) || schedule.Hours != cronBits(0) || schedule.DaysOfWeek != cronBits(0) || !schedule.AnyDay {
+		t.Errorf("Expected @weekly to mean midnight on Sundays, got %+v", schedule)
+	}
+
+	// Sunday can be given as 7 as well as 0
+	if schedule, err = ParseCronSchedule("0 0 * * 7"); err != nil || schedule.DaysOfWeek&cronBits(0) == 0 {
+		t.Errorf("Expected 7 to stand for Sunday, got %+v (%v)", schedule, err)
+	}
+
+	tests := []struct {
+		expression string
+		anyDay     bool
+	}{
+		{"0 0 * * *", true},
+		{"0 0 13 * *", true},
+		{"0 0 * * 5", true},
+		{"0 0 */2 * *", true},
+		{"0 0 13 * 5", false},
+		{"0 0 1-7 * mon", false},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseCronSchedule(test.expression)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %s", test.expression, err)
+		} else if schedule.AnyDay != test.anyDay {
+			t.Errorf("Expected %q to have AnyDay %t", test.expression, test.anyDay)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return parsed
+	}
+
+	tests := []struct {
+		expression string
+		after      string
+		next       string
+	}{
+		{"* * * * *", "2026-10-16 10:07:30", "2026-10-16 10:08:00"},
+		{"*/15 * * * *", "2026-10-16 10:07:30", "2026-10-16 10:15:00"},
+		{"*/15 * * * *", "2026-10-16 10:15:00", "2026-10-16 10:30:00"},
+		{"0 * * * *", "2026-10-16 23:59:00", "2026-10-17 00:00:00"},
+		{"0 9 * * 1-5", "2026-10-16 10:00:00", "2026-10-19 09:00:00"},
+		{"0 0 1 * *", "2026-01-31 12:00:00", "2026-02-01 00:00:00"},
+		{"30 23 31 12 *", "2026-12-31 23:30:00", "2027-12-31 23:30:00"},
+		{"0 0 29 2 *", "2026-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"0 12 * jun-aug *", "2026-10-16 00:00:00", "2027-06-01 12:00:00"},
+		// Day of the month or day of the week, whichever comes first
+		{"0 0 13 * 5", "2026-10-01 00:00:00", "2026-10-02 00:00:00"},
+		{"0 0 13 * 5", "2026-10-10 00:00:00", "2026-10-13 00:00:00"},
+		// With one of them a wildcard only the other one counts
+		{"0 0 13 * *", "2026-10-01 00:00:00", "2026-10-13 00:00:00"},
+		{"0 0 * * 5", "2026-10-03 00:00:00", "2026-10-09 00:00:00"},
+		{"0 0 */10 * *", "2026-10-02 00:00:00", "2026-10-11 00:00:00"},
+	}
+
+	for _, test := range tests {
+		schedule, err := ParseCronSchedule(test.expression)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %s", test.expression, err)
+			continue
+		}
+
+		if next := schedule.Next(at(test.after)); !next.Equal(at(test.next)) {
+			t.Errorf("Expected %q after %s to run at %s, got %s", test.expression, test.after, test.next, next)
+		}
+	}
+
+	// The 31st of February never comes around
+	schedule, _ := ParseCronSchedule("0 0 31 2 *")
+	if next := schedule.Next(at("2026-10-16 00:00:00")); !next.IsZero() {
+		t.Errorf("Expected an impossible schedule never to run, got %s", next)
+	}
+}
@@ -20,6 +20,9 @@ const (
 
 	// ErrorTypeGenericError the generic error type
 	ErrorTypeGenericError = "error"
+
+	// ErrorTypeActionFailure the error type for a service action that failed
+	ErrorTypeActionFailure = "action_failure"
 )
 
 var (
@@ -32,12 +35,167 @@ var (
 	// ErrorCannotModifyService the error for when a service cannot be modified
 	ErrorCannotModifyService = errors.New("The service is running and cannot be modified")
 
+	// ErrorCannotModifyReplica the error for when a replica is updated rather
+	// than the service it's a replica of
+	ErrorCannotModifyReplica = errors.New("The service is a replica and is configured through its original")
+
 	// ErrorCannotMatchProject the error for when a project update cannot be matched
 	ErrorCannotMatchProject = errors.New("The project could not be matched")
 
 	// ErrorCannotMatchService the error for when a service update cannot be matched
 	ErrorCannotMatchService = errors.New("The service could not be matched")
 
+	// ErrorServiceNotInspectable the error for when the processes of a
+	// service that has none of its own are asked for
+	ErrorServiceNotInspectable = errors.New("The service has no processes to inspect")
+
+	// ErrorServiceNotInteractive the error for when a service can't take input
+	ErrorServiceNotInteractive = errors.New("The service does not accept input")
+
+	// ErrorServiceNotRestarted the error for when a service was stopped for a
+	// restart but didn't come back up
+	ErrorServiceNotRestarted = errors.New("The service could not be restarted")
+
+	// ErrorServiceNotRunning the error for when an action needs a running
+	// service
+	ErrorServiceNotRunning = errors.New("The service is not running")
+
+	// ErrorCannotWriteInput the error for when input couldn't be delivered
+	ErrorCannotWriteInput = errors.New("The input could not be written to the service")
+
+	// ErrorMissingArgv the error for when an exec mode service has nothing
+	// to execute
+	ErrorMissingArgv = errors.New("The service is in exec mode but has no argv to execute")
+
+	// ErrorCgroupsUnavailable the error for when the host doesn't have a
+	// cgroup v2 hierarchy we can use
+	ErrorCgroupsUnavailable = errors.New("The cgroup v2 hierarchy is not available")
+
+	// ErrorInvalidLimits the error for when a service's resource limits
+	// make no sense
+	ErrorInvalidLimits = errors.New("The resource limits cannot be negative")
+
+	// ErrorLimitsUnsupported the error for when resource limits are asked
+	// for on a platform we can't apply them on
+	ErrorLimitsUnsupported = errors.New("Resource limits are not supported on this platform")
+
+	// ErrorInvalidAlertRule the error for when an alert rule has no pattern
+	// to look for
+	ErrorInvalidAlertRule = errors.New("Every alert rule needs a pattern")
+
+	// ErrorDuplicatePorts the error for when two services of a project, or
+	// two replicas of one, would end up fixing the same port
+	ErrorDuplicatePorts = errors.New("Fixed ports can't be shared between services or replicas")
+
+	// ErrorInvalidPorts the error for when a service's ports aren't real
+	// ports or two of them would end up in the same variable
+	ErrorInvalidPorts = errors.New("The ports must be between 0 and 65535 and have unique names")
+
+	// ErrorNoFreePorts the error for when every port in the range is taken
+	ErrorNoFreePorts = errors.New("There are no free ports left in the port range")
+
+	// ErrorWorkingDirNotEmpty the error for when a repository can't be cloned
+	// since the working dir already has something else in it
+	ErrorWorkingDirNotEmpty = errors.New("The working dir is not empty and is not a git repository")
+
+	// ErrorWatchUnsupported the error for when file watching is asked for on
+	// a platform we can't watch files on
+	ErrorWatchUnsupported = errors.New("File watching is not supported on this platform")
+
+	// ErrorDetachedNotSupported the error for when a scheduled or task
+	// service asks to be detached. Their runs are followed to completion,
+	// which can't be picked up again by the next orchestra
+	ErrorDetachedNotSupported = errors.New("Only runnable services can be detached")
+
+	// ErrorDetachedTerminal the error for when a service asks to be both
+	// detached and run in a terminal, which would go away along with us
+	ErrorDetachedTerminal = errors.New("A detached service cannot run in a pseudo-terminal")
+
+	// ErrorInvalidSchedule the error for when a scheduled service doesn't
+	// have exactly one of a cron expression and an interval
+	ErrorInvalidSchedule = errors.New("A scheduled service needs either a cron expression or an interval, but not both")
+
+	// ErrorInvalidReplicas the error for when a service is given a replica
+	// count that's out of range
+	ErrorInvalidReplicas = errors.New("The replica count must be between 1 and 64")
+
+	// ErrorInvalidPtySize the error for when a terminal is resized to
+	// something that makes no sense
+	ErrorInvalidPtySize = errors.New("The terminal size must be a positive number of columns and rows")
+
+	// ErrorPtyUnsupported the error for when a service asks for a terminal
+	// on a platform we can't allocate one on
+	ErrorPtyUnsupported = errors.New("Pseudo-terminals are not supported on this platform")
+
+	// ErrorServiceAdopted the error for when input is sent to a service that
+	// was adopted from a previous orchestra, since we never had its stdin
+	ErrorServiceAdopted = errors.New("The service was adopted from a previous run of orchestra, so its input is not connected")
+
+	// ErrorServiceDetached the error for when input is sent to a detached
+	// service, which has nothing on its stdin
+	ErrorServiceDetached = errors.New("The service is detached, so its input is not connected")
+
+	// ErrorServiceAlreadyRunning the error for when a task is run again while
+	// its last run is still going
+	ErrorServiceAlreadyRunning = errors.New("The service is already running")
+
+	// ErrorServiceNoHistory the error for when the run history is requested
+	// for a service that doesn't keep one
+	ErrorServiceNoHistory = errors.New("The service does not keep a run history")
+
+	// ErrorServiceNotScalable the error for when a service that can't have
+	// replicas is asked to scale
+	ErrorServiceNotScalable = errors.New("The service cannot be scaled")
+
+	// ErrorServiceNotSignalable the error for when a signal is sent to a
+	// service that has no process to send it to
+	ErrorServiceNotSignalable = errors.New("The service cannot be sent signals")
+
+	// ErrorServiceNotTask the error for when a service that isn't a task is
+	// asked to run to completion
+	ErrorServiceNotTask = errors.New("The service is not a task")
+
+	// ErrorServiceNotTerminal the error for when a terminal operation is
+	// attempted against a service that isn't running in one
+	ErrorServiceNotTerminal = errors.New("The service is not running in a pseudo-terminal")
+
+	// ErrorTaskNotStarted the error for when a task fails before it even
+	// gets going, the reason for which is in its log
+	ErrorTaskNotStarted = errors.New("The task could not be started")
+
+	// ErrorUnknownOrphanPolicy the error for when a project asks for an
+	// orphan policy we don't support
+	ErrorUnknownOrphanPolicy = errors.New("The orphan policy is not supported")
+
+	// ErrorUnknownOverlapPolicy the error for when a scheduled service asks
+	// for an overlap policy we don't support
+	ErrorUnknownOverlapPolicy = errors.New("The overlap policy is not supported")
+
+	// ErrorUnknownRestartPolicy the error for when a service asks for a
+	// restart policy we don't support
+	ErrorUnknownRestartPolicy = errors.New("The restart policy must be one of never, on-failure or always")
+
+	// ErrorUnknownLaunchMode the error for when a service asks for a launch
+	// mode we don't support
+	ErrorUnknownLaunchMode = errors.New("The launch mode is not supported")
+
+	// ErrorProcessNotInService the error for when a process is picked out
+	// that doesn't belong to the service
+	ErrorProcessNotInService = errors.New("The process does not belong to the service")
+
+	// ErrorCannotKillLeader the error for when the leader of a service's
+	// process group is picked out to be killed on its own, which is what
+	// stopping the service is for
+	ErrorCannotKillLeader = errors.New("The service's main process has to be stopped instead")
+
+	// ErrorCommandTimedOut the error for when a hook, rebuild or clone
+	// doesn't finish in time and gets killed
+	ErrorCommandTimedOut = errors.New("The command timed out")
+
+	// ErrorCannotParseProc the error for when something in /proc isn't laid
+	// out the way we expect
+	ErrorCannotParseProc = errors.New("The process information could not be parsed")
+
 	// ErrorCannotParsePayload the error for when we don't know what the fuck someone
 	// is trying to tell us
 	ErrorCannotParsePayload = errors.New("The payload could not be understood by the server")
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// WatchDefaultDebounce the default number of milliseconds to wait for
+	// changes to settle down before acting on them
+	WatchDefaultDebounce = 500
+
+	// WatchReportLimit how many of the changed files get named in the log
+	WatchReportLimit = 5
+)
+
+var (
+	// WatchIgnoredDirs the directories that are never worth watching
+	WatchIgnoredDirs = []string{".git", ".hg", ".svn"}
+)
+
+// WatchConfiguration the configuration for restarting a service when its
+// files change. The globs are relative to the working dir, a glob without a
+// slash matches against the file name alone and ** matches any number of
+// directories. No includes means every file is included
+type WatchConfiguration struct {
+	DebounceMS int      `json:"debounce_ms,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+	Include    []string `json:"include,omitempty"`
+	Rebuild    string   `json:"rebuild,omitempty"`
+}
+
+// FileWatcher watches the working dir of a service and restarts the service
+// once a batch of changes has settled down
+type FileWatcher struct {
+	Config   *WatchConfiguration
+	Done     chan struct{}
+	Notifier fileNotifier
+	Root     string
+	Service  *RunnableServiceConfiguration
+}
+
+// fileEvent a single change to a file or directory
+type fileEvent struct {
+	Created bool
+	IsDir   bool
+	Path    string
+}
+
+// fileNotifier is what each platform has to provide for the watcher to work
+type fileNotifier interface {
+	Add(dir string) error
+	Close() error
+	Events() <-chan fileEvent
+}
+
+// matchGlob tests whether the slash separated path matches the pattern
+func matchGlob(pattern, path string) bool {
+	if !strings.Contains(pattern, "/") {
+		matched, _ := filepath.Match(pattern, filepath.Base(path))
+		return matched
+	}
+
+	return matchGlobParts(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+// matchGlobParts matches a path against a pattern a directory at a time so
+// that ** can soak up as many directories as it needs to
+func matchGlobParts(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchGlobParts(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+
+		if matched, _ := filepath.Match(pattern[0], path[0]); !matched {
+			return false
+		}
+
+		pattern, path = pattern[1:], path[1:]
+	}
+
+	return len(path) == 0
+}
+
+// GetDebounce returns how long changes have to settle down for
+func (w *WatchConfiguration) GetDebounce() time.Duration {
+	if w.DebounceMS <= 0 {
+		return WatchDefaultDebounce * time.Millisecond
+	}
+
+	return time.Duration(w.DebounceMS) * time.Millisecond
+}
+
+// Matches tests whether a change to the given file (relative to the working
+// dir) should be acted on
+func (w *WatchConfiguration) Matches(path string) bool {
+	for _, pattern := range w.Exclude {
+		if matchGlob(pattern, path) {
+			return false
+		}
+	}
+
+	if len(w.Include) == 0 {
+		return true
+	}
+
+	for _, pattern := range w.Include {
+		if matchGlob(pattern, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SkipsDir tests whether the given directory (relative to the working dir)
+// is excluded wholesale, in which case it isn't even worth watching
+func (w *WatchConfiguration) SkipsDir(path string) bool {
+	for _, ignored := range WatchIgnoredDirs {
+		if filepath.Base(path) == ignored {
+			return true
+		}
+	}
+
+	// Since ** can match nothing at all, "dir/**" matches the dir itself
+	for _, pattern := range w.Exclude {
+		if matchGlob(pattern, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewFileWatcher creates a watcher over the working dir of the service
+func NewFileWatcher(service *RunnableServiceConfiguration) (*FileWatcher, error) {
+	notifier, err := newFileNotifier()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &FileWatcher{
+		Config:   service.Watch,
+		Done:     make(chan struct{}),
+		Notifier: notifier,
+		Root:     service.GetWorkingDir(),
+		Service:  service}
+
+	if err = w.addTree(w.Root); err != nil {
+		notifier.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// addTree watches the given directory and every directory under it that
+// isn't excluded
+func (w *FileWatcher) addTree(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Things get deleted out from under us all the time
+			if os.IsNotExist(err) && path != root {
+				return nil
+			}
+
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if path != w.Root && w.Config.SkipsDir(w.relative(path)) {
+			return filepath.SkipDir
+		}
+
+		return w.Notifier.Add(path)
+	})
+}
+
+// relative returns the slash separated path relative to the working dir
+func (w *FileWatcher) relative(path string) string {
+	rel, err := filepath.Rel(w.Root, path)
+	if err != nil {
+		return path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// Run collects changes until they settle down and then acts on them, until
+// the watcher is stopped
+func (w *FileWatcher) Run() {
+	changed := make(map[string]bool)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.Done:
+			timer.Stop()
+			return
+		case event, ok := <-w.Notifier.Events():
+			if !ok {
+				return
+			}
+
+			rel := w.relative(event.Path)
+			if event.IsDir {
+				w.watchCreated(event, rel)
+				continue
+			}
+
+			if w.Config.Matches(rel) {
+				changed[rel] = true
+				timer.Reset(w.Config.GetDebounce())
+			}
+		case <-timer.C:
+			files := make([]string, 0, len(changed))
+			for file := range changed {
+				files = append(files, file)
+			}
+			changed = make(map[string]bool)
+
+			sort.Strings(files)
+			w.handleChanges(files)
+		}
+	}
+}
+
+// watchCreated starts watching a directory that's just been created, along
+// with everything in it
+func (w *FileWatcher) watchCreated(event fileEvent, rel string) {
+	if !event.Created || w.Config.SkipsDir(rel) {
+		return
+	}
+
+	if err := w.addTree(event.Path); err != nil {
+		Debug.Println("Could not watch", event.Path+":", err)
+	}
+}
+
+// discardEvents throws away the changes that come in until things have been
+// quiet for the debounce period. The rebuild's own output would otherwise
+// set off another rebuild, and so on forever. The directories it creates
+// still get watched
+func (w *FileWatcher) discardEvents() {
+	debounce := w.Config.GetDebounce()
+	quiet := time.NewTimer(debounce)
+	defer quiet.Stop()
+
+	for {
+		select {
+		case <-w.Done:
+			return
+		case event, ok := <-w.Notifier.Events():
+			if !ok {
+				return
+			}
+
+			if event.IsDir {
+				w.watchCreated(event, w.relative(event.Path))
+			}
+			quiet.Reset(debounce)
+		case <-quiet.C:
+			return
+		}
+	}
+}
+
+// Stop stops watching for changes
+func (w *FileWatcher) Stop() {
+	close(w.Done)
+	w.Notifier.Close()
+}
+
+// handleChanges rebuilds the service if need be, and then restarts it
+func (w *FileWatcher) handleChanges(files []string) {
+	summary := strings.Join(files, ", ")
+	if len(files) > WatchReportLimit {
+		summary = fmt.Sprintf("%s and %d more", strings.Join(files[:WatchReportLimit], ", "),
+			len(files)-WatchReportLimit)
+	}
+
+	logServiceMessage(w.Service, fmt.Sprintf("Detected changes to %s", summary))
+
+	if w.Config.Rebuild != "" {
+		err := w.rebuild()
+		w.discardEvents()
+
+		if err != nil {
+			logServiceMessage(w.Service, "Rebuild failed ("+err.Error()+"), not restarting")
+			return
+		}
+
+		logServiceMessage(w.Service, "Rebuild succeeded")
+	}
+
+	// Make sure we weren't stopped while the rebuild was going
+	select {
+	case <-w.Done:
+		logServiceMessage(w.Service, "Watcher was stopped, not restarting")
+		return
+	default:
+	}
+
+	logServiceMessage(w.Service, "Restarting for the file changes")
+	w.Service.restartForChanges()
+}
+
+// rebuild runs the rebuild command, copying its output into the service log
+func (w *FileWatcher) rebuild() error {
+	logServiceMessage(w.Service, "Running rebuild: "+w.Config.Rebuild)
+
+	cmd, err := w.Service.ShellCommand(w.Config.Rebuild)
+	if err != nil {
+		return err
+	}
+
+	return runLoggedCommand(cmd, LoggedCommandTimeout, w.Service)
+}
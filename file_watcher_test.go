@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matched bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/deep/main.go", true},
+		{"*.go", "main.js", false},
+		{"src/*.js", "src/app.js", true},
+		{"src/*.js", "src/lib/app.js", false},
+		{"src/**/*.js", "src/app.js", true},
+		{"src/**/*.js", "src/lib/deep/app.js", true},
+		{"src/**", "src", true},
+		{"src/**", "other/app.js", false},
+		{"**/testdata/*", "a/b/testdata/x", true},
+	}
+
+	for _, test := range tests {
+		if matchGlob(test.pattern, test.path) != test.matched {
+			t.Errorf("Expected %q matching %q to be %t", test.pattern, test.path, test.matched)
+		}
+	}
+}
+
+func TestWatchConfigurationMatches(t *testing.T) {
+	everything := &WatchConfiguration{Exclude: []string{"*.log", "build/**"}}
+	if !everything.Matches("src/main.go") {
+		t.Error("Expected no includes to match every file")
+	}
+
+	if everything.Matches("server.log") || everything.Matches("build/out/app") {
+		t.Error("Expected the excluded files not to match")
+	}
+
+	if !everything.SkipsDir("build") || !everything.SkipsDir("src/.git") || everything.SkipsDir("src") {
+		t.Error("Expected only the excluded and ignored dirs to be skipped")
+	}
+
+	included := &WatchConfiguration{Include: []string{"*.go"}, Exclude: []string{"vendor/**"}}
+	if !included.Matches("cmd/main.go") || included.Matches("README.md") || included.Matches("vendor/lib/lib.go") {
+		t.Error("Expected only the included files outside of the excluded dirs to match")
+	}
+}
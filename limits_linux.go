@@ -0,0 +1,141 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// rlimitNproc the resource number for RLIMIT_NPROC, which the syscall
+	// package doesn't export
+	rlimitNproc = 6
+)
+
+var (
+	// rlimitNames the rlimits the limits wrapper knows how to set, by the
+	// name they're passed to it under
+	rlimitNames = map[string]int{
+		"as":     syscall.RLIMIT_AS,
+		"nofile": syscall.RLIMIT_NOFILE,
+		"nproc":  rlimitNproc,
+	}
+)
+
+// prlimit sets both the soft and hard limit of a resource for a process, or
+// for ourselves when the pid is zero
+func prlimit(pid, resource int, limit uint64) error {
+	rlimit := syscall.Rlimit{Cur: limit, Max: limit}
+
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// startInCgroup has the command started straight into the cgroup at the
+// given path, rather than being moved there once it's already running. The
+// directory handed back has to stay open until the command has started
+func startInCgroup(command *exec.Cmd, path string) (*os.File, error) {
+	dir, err := os.OpenFile(path, os.O_RDONLY|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	command.SysProcAttr.UseCgroupFD = true
+	command.SysProcAttr.CgroupFD = int(dir.Fd())
+
+	return dir, nil
+}
+
+// wrapRlimits has the command started through the limits wrapper, which
+// sets the rlimits on itself and then execs the real command, so that the
+// command and everything it forks inherit them from the very start. The
+// address space and process count limits are only a fallback for when a
+// cgroup can't be used, since the process count covers every process the
+// user owns rather than just the service's
+func wrapRlimits(command *exec.Cmd, limits *ResourceLimits, fallback bool) error {
+	var specs []string
+	if limits.MaxOpenFiles > 0 {
+		specs = append(specs, fmt.Sprintf("nofile=%d", limits.MaxOpenFiles))
+	}
+
+	if fallback && limits.MaxProcesses > 0 {
+		specs = append(specs, fmt.Sprintf("nproc=%d", limits.MaxProcesses))
+	}
+
+	if fallback && limits.MaxMemory > 0 {
+		specs = append(specs, fmt.Sprintf("as=%d", uint64(limits.MaxMemory)*1024*1024))
+	}
+
+	if len(specs) == 0 {
+		return nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{self, LimitsWrapperArg}, specs...)
+	args = append(append(args, "--", command.Path), command.Args[1:]...)
+
+	command.Path = self
+	command.Args = args
+	return nil
+}
+
+// runLimitsWrapper is what the limits wrapper runs as. It takes the rlimits
+// to set, then the command to exec once they're in place. It never returns
+func runLimitsWrapper(args []string) {
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "orchestra: could not apply the resource limits:", err)
+		os.Exit(127)
+	}
+
+	for len(args) > 0 && args[0] != "--" {
+		parts := strings.SplitN(args[0], "=", 2)
+		resource, ok := rlimitNames[parts[0]]
+		if !ok || len(parts) != 2 {
+			fail(ErrorInvalidLimits)
+		}
+
+		limit, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			fail(err)
+		}
+
+		// The open file limit goes through Setrlimit so the runtime doesn't
+		// put back the limit it started with when we exec
+		if resource == syscall.RLIMIT_NOFILE {
+			err = syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit})
+		} else {
+			err = prlimit(0, resource, limit)
+		}
+
+		if err != nil {
+			fail(err)
+		}
+
+		args = args[1:]
+	}
+
+	if len(args) < 2 {
+		fail(ErrorMissingArgv)
+	}
+
+	fail(syscall.Exec(args[1], args[1:], os.Environ()))
+}
@@ -0,0 +1,76 @@
+// +build linux
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsReachForkedGrandchild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := &ProjectConfiguration{Name: "limits"}
+	service := ServiceInterfaces[TypeRunnableService].Create(map[string]interface{}{
+		"type":        TypeRunnableService,
+		"name":        "limits",
+		"working_dir": dir,
+		"limits":      map[string]interface{}{"max_open_files": 64, "max_processes": 50},
+		"commands": []string{
+			"sh -c 'sh -c \"cat /proc/self/limits /proc/self/cgroup\"'",
+		},
+	}, project).(*RunnableServiceConfiguration)
+
+	if !service.Start() {
+		t.Fatal("Expected the service to start")
+	}
+	process := service.getProcess()
+	<-process.Exited
+
+	// The exit listener may still be logging, so the log is read under its
+	// lock
+	var lines []string
+	logs := service.GetLogs()
+	logs.Mutex.Lock()
+	for entry := logs.Root; entry != nil; entry = entry.Next {
+		lines = append(lines, entry.Line)
+	}
+	logs.Mutex.Unlock()
+
+	limits := make(map[string]string)
+	for _, line := range lines {
+
+		// The limit lines read like "Max open files  64  64  files"
+		for _, name := range []string{"Max open files", "Max processes"} {
+			if fields := strings.Fields(strings.TrimPrefix(line, name)); strings.HasPrefix(line, name) && len(fields) > 0 {
+				limits[name] = fields[0]
+			}
+		}
+	}
+	output := strings.Join(lines, "\n")
+
+	if limits["Max open files"] != "64" {
+		t.Errorf("Expected the grandchild to inherit the open file limit, got:\n%s", output)
+	}
+
+	// Without a cgroup to put the process count in, it falls back on the
+	// rlimit, which the grandchild has to inherit just the same
+	if process.Cgroup == "" {
+		if limits["Max processes"] != "50" {
+			t.Errorf("Expected the grandchild to inherit the process limit, got:\n%s", output)
+		}
+	} else if !strings.Contains(output, "::/"+strings.TrimPrefix(process.Cgroup, CgroupMount+string(filepath.Separator))) {
+		t.Errorf("Expected the grandchild to start in the cgroup %s, got:\n%s", process.Cgroup, output)
+	}
+
+	// Give the exit listener a moment to finish its bookkeeping
+	time.Sleep(100 * time.Millisecond)
+}
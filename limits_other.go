@@ -0,0 +1,24 @@
+// +build !linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// startInCgroup is only supported on linux
+func startInCgroup(command *exec.Cmd, path string) (*os.File, error) {
+	return nil, ErrorCgroupsUnavailable
+}
+
+// wrapRlimits is only supported on linux
+func wrapRlimits(command *exec.Cmd, limits *ResourceLimits, fallback bool) error {
+	return ErrorLimitsUnsupported
+}
+
+// runLimitsWrapper is only supported on linux, and never gets asked for
+// anywhere else
+func runLimitsWrapper(args []string) {
+	os.Exit(127)
+}
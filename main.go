@@ -35,6 +35,10 @@ var (
 	// current environment
 	OrchestraEnvVar = "ORCH_ENV"
 
+	// OrchestraHomeEnvVar the environment variable to use for keeping the
+	// orchestra home somewhere other than the user's home directory
+	OrchestraHomeEnvVar = "ORCH_HOME"
+
 	// OrchestraVersion the current version of Orchestra
 	OrchestraVersion = "0.2.1"
 )
@@ -42,6 +46,12 @@ var (
 //go:generate go-bindata -o assets.go assets/...
 
 func main() {
+	// Services with rlimits get started through us, and we get out of the
+	// way as soon as they're set
+	if len(os.Args) > 1 && os.Args[1] == LimitsWrapperArg {
+		runLimitsWrapper(os.Args[2:])
+	}
+
 	OrchestraVersion = fmt.Sprint(OrchestraVersion, ".", OrchestraBuildVersion)
 	if os.Getenv(OrchestraEnvVar) != "" {
 		// Make sure the casing is what we expect too
@@ -55,6 +65,10 @@ func main() {
 	Config = loadConfiguration(DefaultConfigName)
 	Info.Println("Starting up with config:", Config)
 
+	// Deal with whatever the last run left behind before anything new gets
+	// started on top of it
+	recoverOrphans()
+
 	go func() {
 		if Config.Server.AcceptAddrHTTPS != "" {
 			Error.Fatal(http.ListenAndServeTLS(Config.Server.GetHTTPSUrl(),
@@ -83,7 +97,7 @@ func main() {
 	for _, project := range Config.Projects {
 		Debug.Println("Halting", project.Name)
 		if project.Running() {
-			project.Stop()
+			project.Halt()
 		}
 	}
 
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Limited services get started through the test binary, rather than
+	// through orchestra
+	if len(os.Args) > 1 && os.Args[1] == LimitsWrapperArg {
+		runLimitsWrapper(os.Args[2:])
+	}
+
+	SetupLoggers()
+
+	// Keep run histories and the like out of the real orchestra home
+	home, err := ioutil.TempDir("", "orchestra-home")
+	if err != nil {
+		Error.Fatal(err)
+	}
+	os.Setenv(OrchestraHomeEnvVar, home)
+
+	// Tests add the projects they drive through the commands in here. The
+	// configuration itself never gets swapped out, since the goroutines of
+	// the processes the tests start read it
+	Config = &Configuration{Projects: make(map[string]*ProjectConfiguration)}
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
@@ -17,7 +17,9 @@ type MockeryServiceConfiguration struct {
 	Description    string                      `json:"description"`
 	DelayAfter     int                         `json:"delay_after"`
 	DelayBefore    int                         `json:"delay_before"`
+	DependsOn      []string                    `json:"depends_on,omitempty"`
 	ID             string                      `json:"id"`
+	Logs           *ServiceLog                 `json:"-"`
 	Name           string                      `json:"name"`
 	Port           string                      `json:"port"`
 	Process        *MockeryProcess             `json:"-"`
@@ -73,16 +75,51 @@ func (s *MockeryServiceConfiguration) Create(newConfig map[string]interface{}, p
 	return runnable
 }
 
+// Fail flags the service as failed and records the reason in its log
+func (s *MockeryServiceConfiguration) Fail(reason error) {
+	Error.Println(s.Name, "failed:", reason)
+
+	s.Running = false
+	s.State = ServiceFailed
+	logServiceMessage(s, reason.Error())
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(s.State, s)
+	})
+}
+
+// GetDelayAfter returns how long the project pauses after starting or
+// stopping the service
+func (s *MockeryServiceConfiguration) GetDelayAfter() time.Duration {
+	return time.Duration(s.DelayAfter) * time.Second
+}
+
+// GetDelayBefore returns how long the project pauses before starting or
+// stopping the service
+func (s *MockeryServiceConfiguration) GetDelayBefore() time.Duration {
+	return time.Duration(s.DelayBefore) * time.Second
+}
+
+func (s *MockeryServiceConfiguration) GetDependencies() []string {
+	return s.DependsOn
+}
+
 func (s *MockeryServiceConfiguration) GetID() string {
 	return s.ID
 }
 
+// GetLogs returns the service log, which is shared across every server run
+// so the history survives restarts
 func (s *MockeryServiceConfiguration) GetLogs() *ServiceLog {
-	if s.Process != nil {
-		return s.Process.Logs
+	if s.Logs == nil {
+		s.Logs = &ServiceLog{}
 	}
 
-	return nil
+	return s.Logs
+}
+
+func (s *MockeryServiceConfiguration) GetName() string {
+	return s.Name
 }
 
 func (s *MockeryServiceConfiguration) GetProject() *ProjectConfiguration {
@@ -93,6 +130,21 @@ func (s *MockeryServiceConfiguration) GetState() string {
 	return s.State
 }
 
+// SetWaiting flags whether the service is waiting to be started
+func (s *MockeryServiceConfiguration) SetWaiting(waiting bool) {
+	if waiting {
+		s.State = ServiceWaiting
+	} else if s.State == ServiceWaiting {
+		s.State = ServiceStopped
+	} else {
+		return
+	}
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(s.State, s)
+	})
+}
+
 // GenerateID generates a new ID
 func (m *MockeryServiceConfiguration) GenerateID() {
 	Debug.Println("Generating ID")
@@ -126,22 +178,24 @@ func (m *MockeryServiceConfiguration) IsMatch(config interface{}) bool {
 
 func (m *MockeryServiceConfiguration) Start() bool {
 	if !m.Running {
+		if err := m.checkPort(); err != nil {
+			m.Fail(err)
+			return false
+		}
+
 		m.Process = &MockeryProcess{
 			Configuration: m,
-			Logs:          &ServiceLog{},
+			Logs:          m.GetLogs(),
 			Mutex:         &sync.Mutex{}}
 
 		m.Process.Start()
 		m.State = ServiceRunning
 
-		for socket, user := range activeUsers {
-			if socket != nil {
-				Info.Println("Telling users about", m.Name)
-				user.ResetSubscription(m)
-				user.WriteStatusMessage(m.State, m)
-				// activeUsers[socket] = user
-			}
-		}
+		ForEachActiveUser(func(user *User) {
+			Info.Println("Telling users about", m.Name)
+			user.ResetSubscription(m)
+			user.WriteStatusMessage(m.State, m)
+		})
 
 		return true
 	}
@@ -251,13 +305,9 @@ func (m *MockeryProcess) Start() {
 			// We just shut down the server, clear it out
 			m.Server = nil
 
-			for socket, user := range activeUsers {
-				if socket != nil {
-					user.WriteStatusMessage(m.Configuration.State, m.Configuration)
-				} else {
-					Warn.Println("User somehow has a missing socket")
-				}
-			}
+			ForEachActiveUser(func(user *User) {
+				user.WriteStatusMessage(m.Configuration.State, m.Configuration)
+			})
 		}()
 
 		m.Configuration.Running = true
@@ -279,7 +329,7 @@ func (m *MockeryProcess) Stop() {
 
 // Update updates the service configuration
 func (m *MockeryServiceConfiguration) Update(newConfig map[string]interface{}) error {
-	if m.GetState() == ServiceRunning {
+	if IsActiveState(m.GetState()) {
 		return ErrorCannotModifyService
 	}
 
@@ -299,6 +349,11 @@ func (m *MockeryServiceConfiguration) Update(newConfig map[string]interface{}) e
 	m.DelayAfter = shimService.DelayAfter
 	m.DelayBefore = shimService.DelayBefore
 	m.Description = shimService.Description
+
+	if shimService.DependsOn != nil {
+		m.DependsOn = shimService.DependsOn
+	}
+
 	if shimService.ID != "" {
 		m.ID = shimService.ID
 	}
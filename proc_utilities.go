@@ -0,0 +1,298 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ProcClockTicks the number of clock ticks per second that /proc reports
+	// CPU time in. This is USER_HZ, which is 100 everywhere that matters
+	ProcClockTicks = 100
+
+	// ProcRoot where the proc filesystem is mounted
+	ProcRoot = "/proc"
+
+	// ProcTCPListen the state /proc/net/tcp reports listening sockets in
+	ProcTCPListen = "0A"
+)
+
+// ProcessStats a snapshot of the resources being used by a whole process
+// group. RSS is in bytes
+type ProcessStats struct {
+	CPUPercent      float64   `json:"cpu_percent"`
+	FileDescriptors int       `json:"file_descriptors"`
+	Processes       int       `json:"processes"`
+	RSS             int64     `json:"rss"`
+	SampledAt       time.Time `json:"sampled_at"`
+	Threads         int       `json:"threads"`
+
+	// cpuTicks the total CPU time used, kept around to work out the usage
+	// between samples
+	cpuTicks int64
+}
+
+// procStat the fields we care about out of /proc/<pid>/stat
+type procStat struct {
+	CPUTicks  int64
+	Pgrp      int
+	PPID      int
+	RSSPages  int64
+	StartTime int64
+	State     string
+	Threads   int
+}
+
+// readProcStat parses /proc/<pid>/stat. The command name is wrapped in
+// parens and may itself contain spaces or parens, so everything is counted
+// from the last closing paren
+func readProcStat(pid int) (*procStat, error) {
+	data, err := ioutil.ReadFile(filepath.Join(ProcRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	contents := string(data)
+	end := strings.LastIndex(contents, ")")
+	if end < 0 {
+		return nil, ErrorCannotParseProc
+	}
+
+	// fields[0] is the state, which is field 3 in the proc(5) numbering
+	fields := strings.Fields(contents[end+1:])
+	if len(fields) < 22 {
+		return nil, ErrorCannotParseProc
+	}
+
+	field := func(n int) int64 {
+		value, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return value
+	}
+
+	return &procStat{
+		CPUTicks:  field(14) + field(15),
+		Pgrp:      int(field(5)),
+		PPID:      int(field(4)),
+		RSSPages:  field(24),
+		StartTime: field(22),
+		State:     fields[0],
+		Threads:   int(field(20))}, nil
+}
+
+// readBootID returns the ID the kernel picked for the current boot, which
+// tells whether PIDs from before are still worth anything
+func readBootID() string {
+	data, err := ioutil.ReadFile(filepath.Join(ProcRoot, "sys", "kernel", "random", "boot_id"))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// countOpenFiles returns the number of open file descriptors of a process
+func countOpenFiles(pid int) int {
+	entries, err := ioutil.ReadDir(filepath.Join(ProcRoot, strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0
+	}
+
+	return len(entries)
+}
+
+// listProcesses returns the PIDs of every process currently visible in /proc
+func listProcesses() ([]int, error) {
+	entries, err := ioutil.ReadDir(ProcRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// listProcessGroup returns the PIDs of every live process in the given
+// group. Zombies are left out, since their parent just hasn't gotten around
+// to reaping them yet
+func listProcessGroup(pgid int) ([]int, error) {
+	pids, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	var members []int
+	for _, pid := range pids {
+		// Processes come and go while we look, so just skip the ones we miss
+		if stat, err := readProcStat(pid); err == nil && stat.Pgrp == pgid && stat.State != "Z" {
+			members = append(members, pid)
+		}
+	}
+
+	return members, nil
+}
+
+// sampleProcessGroup takes a snapshot of the resources used by every process
+// in the given group. CPU usage is worked out relative to the previous
+// sample, if there is one
+func sampleProcessGroup(pgid int, previous *ProcessStats) (*ProcessStats, error) {
+	pids, err := listProcessGroup(pgid)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ProcessStats{SampledAt: time.Now()}
+	pageSize := int64(os.Getpagesize())
+
+	for _, pid := range pids {
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+
+		stats.cpuTicks += stat.CPUTicks
+		stats.FileDescriptors += countOpenFiles(pid)
+		stats.Processes++
+		stats.RSS += stat.RSSPages * pageSize
+		stats.Threads += stat.Threads
+	}
+
+	if previous != nil {
+		elapsed := stats.SampledAt.Sub(previous.SampledAt).Seconds()
+
+		// A child exiting takes its CPU time with it, which would make the
+		// usage look negative
+		if ticks := stats.cpuTicks - previous.cpuTicks; elapsed > 0 && ticks > 0 {
+			stats.CPUPercent = float64(ticks) / ProcClockTicks / elapsed * 100
+		}
+	}
+
+	return stats, nil
+}
+
+// listListeningPorts returns the inode of every listening TCP socket, over
+// both IPv4 and IPv6, by the port it's listening on
+func listListeningPorts() (map[int]uint64, error) {
+	listening := make(map[int]uint64)
+
+	for _, table := range []string{"tcp", "tcp6"} {
+		data, err := ioutil.ReadFile(filepath.Join(ProcRoot, "net", table))
+		if err != nil {
+			// Hosts without IPv6 don't have the second table at all
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		// The first line is just the column headers
+		lines := strings.Split(string(data), "\n")
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 || fields[3] != ProcTCPListen {
+				continue
+			}
+
+			// The local address is a hex address and a hex port
+			separator := strings.LastIndex(fields[1], ":")
+			if separator < 0 {
+				continue
+			}
+
+			port, err := strconv.ParseUint(fields[1][separator+1:], 16, 16)
+			if err != nil {
+				continue
+			}
+
+			inode, _ := strconv.ParseUint(fields[9], 10, 64)
+			listening[int(port)] = inode
+		}
+	}
+
+	return listening, nil
+}
+
+// findSocketOwner returns the PID of a process holding the socket with the
+// given inode open, or zero if none of the processes we can see have it
+func findSocketOwner(inode uint64) int {
+	pids, err := listProcesses()
+	if err != nil {
+		return 0
+	}
+
+	target := "socket:[" + strconv.FormatUint(inode, 10) + "]"
+	for _, pid := range pids {
+		dir := filepath.Join(ProcRoot, strconv.Itoa(pid), "fd")
+
+		// Other users' processes can't be looked into, so just skip them
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if link, err := os.Readlink(filepath.Join(dir, entry.Name())); err == nil && link == target {
+				return pid
+			}
+		}
+	}
+
+	return 0
+}
+
+// readProcessName returns the command name of a process
+func readProcessName(pid int) string {
+	data, err := ioutil.ReadFile(filepath.Join(ProcRoot, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// readCommandLine returns the arguments a process was started with, joined
+// by spaces. Kernel threads and zombies have none, so like ps we fall back
+// on the command name in brackets
+func readCommandLine(pid int) string {
+	data, err := ioutil.ReadFile(filepath.Join(ProcRoot, strconv.Itoa(pid), "cmdline"))
+	if err == nil {
+		if args := strings.TrimRight(string(data), "\x00"); args != "" {
+			return strings.Replace(args, "\x00", " ", -1)
+		}
+	}
+
+	return "[" + readProcessName(pid) + "]"
+}
+
+// readBootTime returns when the system booted, which process start times in
+// /proc are counted from
+func readBootTime() (time.Time, error) {
+	data, err := ioutil.ReadFile(filepath.Join(ProcRoot, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			seconds, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, ErrorCannotParseProc
+			}
+
+			return time.Unix(seconds, 0), nil
+		}
+	}
+
+	return time.Time{}, ErrorCannotParseProc
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ProjectAPIPrefix the route prefix that all of the project endpoints
+	// live under
+	ProjectAPIPrefix = "/api/v1/project/"
+)
+
+// ProjectActionHandler handles a single REST action against a project
+type ProjectActionHandler func(rw http.ResponseWriter, req *http.Request, project *ProjectConfiguration)
+
+var (
+	// ProjectActions the set of actions that can be performed against a
+	// project via /api/v1/project/<id>/<action>
+	ProjectActions = map[string]ProjectActionHandler{
+		CommandRestart: HandleProjectRestart,
+	}
+)
+
+// HandleProjectRestart restarts every service of a project. Starting back up
+// can wait on readiness probes for a good while, so the restart carries on
+// in the background and the request is only accepted
+func HandleProjectRestart(rw http.ResponseWriter, req *http.Request, project *ProjectConfiguration) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	go project.Restart(requestingUser(req))
+
+	rw.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(rw).Encode(&ErrorReport{})
+}
+
+// handleProjectAction dispatches the named action against the project with
+// the given ID
+func handleProjectAction(rw http.ResponseWriter, req *http.Request, projectID, action string) {
+	handler, ok := ProjectActions[action]
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	project := findProject(IncomingSocketCommand{ProjectID: projectID})
+	if project == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	handler(rw, req, project)
+}
+
+// parseProjectPath splits a project route into the project ID and the
+// (optional) action being performed against it
+func parseProjectPath(path string) (string, string) {
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(path, ProjectAPIPrefix), "/"), "/", 2)
+
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return parts[0], ""
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 // ProjectConfiguration the struct for holding a particular set of configurations
 type ProjectConfiguration struct {
-	ID                string        `json:"id"`
-	Name              string        `json:"name"`
-	Description       string        `json:"description"`
-	Services          []interface{} `json:"services"`
-	TemporaryServices []interface{} `json:"-"` // TemporaryServices do not get saved
+	Cancel            context.CancelFunc `json:"-"`
+	Env               map[string]string  `json:"env,omitempty"`
+	ID                string             `json:"id"`
+	Mutex             sync.Mutex         `json:"-"`
+	Name              string             `json:"name"`
+	Description       string             `json:"description"`
+	OrphanPolicy      string             `json:"orphan_policy,omitempty"`
+	PostStart         []string           `json:"post_start,omitempty"`
+	PostStop          []string           `json:"post_stop,omitempty"`
+	PreStart          []string           `json:"pre_start,omitempty"`
+	PreStop           []string           `json:"pre_stop,omitempty"`
+	Services          []interface{}      `json:"services"`
+	TemporaryServices []interface{}      `json:"-"` // TemporaryServices do not get saved
 }
 
 // GenerateID generates a new ID
@@ -38,7 +49,7 @@ func (p *ProjectConfiguration) Running() bool {
 	for _, service := range p.Services {
 		genericService := service.(ServiceInterface)
 		// If any service is running, then the project is running
-		if genericService.GetState() == ServiceRunning {
+		if IsActiveState(genericService.GetState()) {
 			return true
 		}
 	}
@@ -46,25 +57,84 @@ func (p *ProjectConfiguration) Running() bool {
 	return false
 }
 
-// Start starts the full project configuration
-func (p *ProjectConfiguration) Start() bool {
+// checkDependencies makes sure every dependency of the given service has
+// come up, returning an error describing the first one that didn't
+func (p *ProjectConfiguration) checkDependencies(ctx context.Context, service ServiceInterface) error {
+	for _, ref := range service.GetDependencies() {
+		dependency := p.findDependency(ref)
+		if dependency == nil {
+			return fmt.Errorf("Not starting %q: dependency %q does not exist", service.GetName(), ref)
+		}
+
+		if err := waitForService(ctx, dependency); err != nil {
+			return fmt.Errorf("Not starting %q: dependency %s", service.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// Start starts the full project configuration, making sure each service is
+// only started once everything it depends on is up. Starting or stopping
+// the project again cancels a start that is still under way. The user is
+// whoever asked for the start, and goes on the record of every run
+func (p *ProjectConfiguration) Start(user string) bool {
+	ordered, err := orderServices(p.Services)
+	if err != nil {
+		Error.Println("Cannot start project", p.Name+":", err)
+		return false
+	}
+
+	ctx := p.beginOperation()
+	if err := p.runHooks(HookPreStart, p.PreStart); err != nil {
+		Error.Println("Cannot start project", p.Name+":", err)
+		for _, service := range ordered {
+			logServiceMessage(service, "Not starting the project: "+err.Error())
+		}
+
+		return false
+	}
+
 	success := true
-	for _, s := range p.Services {
+	for _, service := range ordered {
 		accepted := false
-		service := s.(ServiceInterface)
 		for _, iface := range ServiceInterfaces {
 			if iface.Accept(service) {
 				accepted = true
-				if service.GetState() == ServiceRunning {
+				started := true
+				before, after := getServiceDelays(service)
+
+				if IsActiveState(service.GetState()) {
 					// Already running, don't try to start it
-				} else {
-					success = success && service.Start()
+				} else if err := p.checkDependencies(ctx, service); err != nil {
+					if ctx.Err() != nil {
+						Info.Println("Start of project", p.Name, "was cancelled")
+						return false
+					}
+
+					service.Fail(err)
+					started = false
+				} else if !pauseForService(ctx, service, before, "before starting", true) {
+					Info.Println("Start of project", p.Name, "was cancelled")
+					return false
+				} else if !IsActiveState(service.GetState()) {
+					// Someone may have started it by hand while we waited
+					if historical, ok := service.(HistoricalService); ok {
+						historical.SetTrigger(TriggerProject, user)
+					}
+
+					started = service.Start()
+					if started && !pauseForService(ctx, service, after, "after starting", false) {
+						Info.Println("Start of project", p.Name, "was cancelled")
+						return false
+					}
 				}
 
-				if !success {
-					Error.Println("There was an error starting up service:", service)
+				if !started {
+					success = false
+					Error.Println("There was an error starting up service:", service.GetName())
 				} else {
-					Debug.Println("Project start -- started", service)
+					Debug.Println("Project start -- started", service.GetName())
 				}
 			}
 		}
@@ -75,21 +145,92 @@ func (p *ProjectConfiguration) Start() bool {
 		}
 	}
 
+	if success {
+		if err := p.runHooks(HookPostStart, p.PostStart); err != nil {
+			Error.Println("Project", p.Name+":", err)
+		}
+	}
+
 	Debug.Println("Project", p.Name, "started?", success)
 	return success
 }
 
-// Stop stops the full project configuration
+// stopOrder returns the services in the order they should be stopped, which
+// is the reverse of the order they get started in
+func (p *ProjectConfiguration) stopOrder() []ServiceInterface {
+	ordered, err := orderServices(p.Services)
+	if err != nil {
+		// Still stop everything, we just can't be smart about the order
+		Error.Println("Cannot order the services for", p.Name+":", err)
+		ordered = make([]ServiceInterface, len(p.Services))
+		for i, s := range p.Services {
+			ordered[i] = s.(ServiceInterface)
+		}
+	}
+
+	for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	}
+
+	return ordered
+}
+
+// Stop stops the full project configuration, stopping dependents before the
+// services they depend on. Any start that is still under way is cancelled
 func (p *ProjectConfiguration) Stop() {
-	for _, s := range p.Services {
+	p.stop()
+}
+
+// Restart stops the full project configuration and starts it back up, in
+// the same order as stopping and starting it separately would. Nothing is
+// started again until every service has gone down
+func (p *ProjectConfiguration) Restart(user string) bool {
+	Info.Println("Restarting the project configuration for", p.Name)
+	if !p.stop() {
+		return false
+	}
+
+	for _, service := range p.Services {
+		restartable, ok := service.(RestartableService)
+		if ok && !restartable.AwaitExit() {
+			Error.Println("Cannot restart project", p.Name+":", service.(ServiceInterface).GetName(),
+				"is still running")
+			return false
+		}
+	}
+
+	return p.Start(user)
+}
+
+// stop does the work of stopping the project, returning false if it was
+// cancelled part way through
+func (p *ProjectConfiguration) stop() bool {
+	ctx := p.beginOperation()
+	if err := p.runHooks(HookPreStop, p.PreStop); err != nil {
+		Error.Println("Project", p.Name+":", err)
+	}
+
+	for _, service := range p.stopOrder() {
 		accepted := false
-		service := s.(ServiceInterface)
 
 		// Get the match here
 		for _, iface := range ServiceInterfaces {
 			if iface.Accept(service) {
 				accepted = true
+				before, after := getServiceDelays(service)
+				active := IsActiveState(service.GetState())
+
+				if active && !pauseForService(ctx, service, before, "before stopping", false) {
+					Info.Println("Stop of project", p.Name, "was cancelled")
+					return false
+				}
+
 				service.Stop()
+
+				if active && !pauseForService(ctx, service, after, "after stopping", false) {
+					Info.Println("Stop of project", p.Name, "was cancelled")
+					return false
+				}
 			}
 		}
 
@@ -98,7 +239,42 @@ func (p *ProjectConfiguration) Stop() {
 		}
 	}
 
+	if err := p.runHooks(HookPostStop, p.PostStop); err != nil {
+		Error.Println("Project", p.Name+":", err)
+	}
+
 	Info.Println("Stopped the project configuration for", p.Name)
+	return true
+}
+
+// Halt stops the project as orchestra shuts down. Detached services are left
+// running for the next orchestra to adopt, so the project only gets stopped
+// in full when it has none of them running
+func (p *ProjectConfiguration) Halt() {
+	// Scheduled and task services can't be detached, so only plain runnable
+	// services need looking at
+	detached := false
+	for _, service := range p.Services {
+		if runnable, ok := service.(*RunnableServiceConfiguration); ok && runnable.Detached && runnable.isRunning() {
+			detached = true
+		}
+	}
+
+	if !detached {
+		p.Stop()
+		return
+	}
+
+	for _, service := range p.stopOrder() {
+		if runnable, ok := service.(*RunnableServiceConfiguration); ok && runnable.Detached {
+			Info.Println("Leaving", runnable.Name, "running since it is detached")
+			continue
+		}
+
+		if IsActiveState(service.GetState()) {
+			service.Stop()
+		}
+	}
 }
 
 // Update updates the project configuration with settings from a new one
@@ -109,13 +285,22 @@ func (p *ProjectConfiguration) Update(newConfig *ProjectConfiguration) error {
 		return ErrorCannotModifyProject
 	}
 
+	// Refuse anything we couldn't order before we start touching the project
+	if err = validateServiceDependencies(newConfig.Services); err != nil {
+		return err
+	}
+
+	if err = p.validateFixedPorts(newConfig.Services); err != nil {
+		return err
+	}
+
 	// We have to do an initial pass to make sure we don't *start* updating
 	// and then run into an actively running service, so we have to do
 	// this loop at least twice
 	for _, s := range p.Services {
 		service := s.(ServiceInterface)
 
-		if service.GetState() == ServiceRunning {
+		if IsActiveState(service.GetState()) {
 			return ErrorCannotModifyService
 		}
 	}
@@ -134,6 +319,35 @@ func (p *ProjectConfiguration) Update(newConfig *ProjectConfiguration) error {
 
 	p.Description = newConfig.Description
 
+	if newConfig.Env != nil {
+		restoreMaskedEnv(p.Env, newConfig.Env)
+		p.Env = newConfig.Env
+	}
+
+	if newConfig.OrphanPolicy != "" {
+		if newConfig.OrphanPolicy != OrphanPolicyAdopt && newConfig.OrphanPolicy != OrphanPolicyReap {
+			return ErrorUnknownOrphanPolicy
+		}
+
+		p.OrphanPolicy = newConfig.OrphanPolicy
+	}
+
+	if newConfig.PostStart != nil {
+		p.PostStart = newConfig.PostStart
+	}
+
+	if newConfig.PostStop != nil {
+		p.PostStop = newConfig.PostStop
+	}
+
+	if newConfig.PreStart != nil {
+		p.PreStart = newConfig.PreStart
+	}
+
+	if newConfig.PreStop != nil {
+		p.PreStop = newConfig.PreStop
+	}
+
 	if p.ID == "" {
 		p.GenerateID()
 	}
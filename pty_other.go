@@ -0,0 +1,23 @@
+// +build !linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// openPty is only supported on linux
+func openPty() (*os.File, *os.File, error) {
+	return nil, nil, ErrorPtyUnsupported
+}
+
+// ptySysProcAttr falls back to the normal process group setup
+func ptySysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// setPtySize is only supported on linux
+func setPtySize(pty *os.File, cols, rows uint16) error {
+	return ErrorPtyUnsupported
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// ProbeTypeCommand probes by running a shell command and checking that it
+	// exits cleanly
+	ProbeTypeCommand = "command"
+
+	// ProbeTypeHTTP probes by issuing a GET and checking the status code
+	ProbeTypeHTTP = "http"
+
+	// ProbeTypeOutput probes by watching the service output for a regex match
+	ProbeTypeOutput = "output"
+
+	// ProbeTypeTCP probes by opening a TCP connection
+	ProbeTypeTCP = "tcp"
+
+	// ProbeDefaultInterval the default number of seconds between attempts
+	ProbeDefaultInterval = 1
+
+	// ProbeDefaultRetries the default number of failed attempts before a
+	// service is considered unhealthy
+	ProbeDefaultRetries = 30
+
+	// ProbeDefaultTimeout the default number of seconds a single attempt
+	// may take
+	ProbeDefaultTimeout = 1
+)
+
+var (
+	// ErrorProbeNotMatched the error for when the output probe has not seen
+	// its pattern yet
+	ErrorProbeNotMatched = errors.New("The output has not matched the readiness pattern yet")
+
+	// ErrorProbeUnknownType the error for when a probe type isn't supported
+	ErrorProbeUnknownType = errors.New("The readiness probe type is not supported")
+)
+
+// ReadinessProbe the configuration for checking whether a service is actually
+// ready to take traffic. Interval and timeout are in seconds
+type ReadinessProbe struct {
+	Command        string `json:"command,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty"`
+	Interval       int    `json:"interval,omitempty"`
+	Pattern        string `json:"pattern,omitempty"`
+	Retries        int    `json:"retries,omitempty"`
+	Target         string `json:"target,omitempty"`
+	Timeout        int    `json:"timeout,omitempty"`
+	Type           string `json:"type"`
+}
+
+// ReadinessCheck tracks a single run of a readiness probe against a single
+// service process
+type ReadinessCheck struct {
+	Matched chan struct{}
+	Once    *sync.Once
+	Pattern *regexp.Regexp
+	Probe   *ReadinessProbe
+}
+
+// NewReadinessCheck creates a new check for the given probe
+func NewReadinessCheck(probe *ReadinessProbe) (*ReadinessCheck, error) {
+	check := &ReadinessCheck{
+		Matched: make(chan struct{}),
+		Once:    &sync.Once{},
+		Probe:   probe}
+
+	if err := probe.Validate(); err != nil {
+		return nil, err
+	}
+
+	if probe.Type == ProbeTypeOutput {
+		check.Pattern = regexp.MustCompile(probe.Pattern)
+	}
+
+	return check, nil
+}
+
+// Validate makes sure the probe is of a type that can be run, and that an
+// output probe's pattern compiles
+func (p *ReadinessProbe) Validate() error {
+	switch p.Type {
+	case ProbeTypeCommand, ProbeTypeHTTP, ProbeTypeTCP:
+		return nil
+	case ProbeTypeOutput:
+		_, err := regexp.Compile(p.Pattern)
+		return err
+	}
+
+	return ErrorProbeUnknownType
+}
+
+// GetInterval returns the time between attempts
+func (p *ReadinessProbe) GetInterval() time.Duration {
+	if p.Interval <= 0 {
+		return ProbeDefaultInterval * time.Second
+	}
+
+	return time.Duration(p.Interval) * time.Second
+}
+
+// GetRetries returns the number of failed attempts that are tolerated
+func (p *ReadinessProbe) GetRetries() int {
+	if p.Retries <= 0 {
+		return ProbeDefaultRetries
+	}
+
+	return p.Retries
+}
+
+// GetTimeout returns how long a single attempt may take
+func (p *ReadinessProbe) GetTimeout() time.Duration {
+	if p.Timeout <= 0 {
+		return ProbeDefaultTimeout * time.Second
+	}
+
+	return time.Duration(p.Timeout) * time.Second
+}
+
+// Check performs a single probe attempt, returning nil if the service
+// looks ready
+func (c *ReadinessCheck) Check(config *RunnableServiceConfiguration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Probe.GetTimeout())
+	defer cancel()
+
+	switch c.Probe.Type {
+	case ProbeTypeCommand:
+		cmd, err := config.ShellCommand(c.Probe.Command)
+		if err != nil {
+			return err
+		}
+
+		if err = cmd.Start(); err != nil {
+			return err
+		}
+
+		// Don't let a hung command outlive its timeout
+		go func() {
+			<-ctx.Done()
+			cmd.Process.Kill()
+		}()
+
+		return cmd.Wait()
+	case ProbeTypeHTTP:
+		req, err := http.NewRequest(http.MethodGet, c.Probe.Target, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		expected := c.Probe.ExpectedStatus
+		if expected == 0 {
+			expected = http.StatusOK
+		}
+
+		if resp.StatusCode != expected {
+			return fmt.Errorf("Expected status %d but got %d", expected, resp.StatusCode)
+		}
+
+		return nil
+	case ProbeTypeOutput:
+		select {
+		case <-c.Matched:
+			return nil
+		case <-ctx.Done():
+			return ErrorProbeNotMatched
+		}
+	case ProbeTypeTCP:
+		conn, err := net.DialTimeout("tcp", c.Probe.Target, c.Probe.GetTimeout())
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	return ErrorProbeUnknownType
+}
+
+// Observe feeds a line of service output to the check so output probes can
+// look for their pattern
+func (c *ReadinessCheck) Observe(line string) {
+	if c.Pattern != nil && c.Pattern.MatchString(line) {
+		c.Once.Do(func() {
+			close(c.Matched)
+		})
+	}
+}
+
+// Run keeps probing the process until it either passes, runs out of retries
+// or the process goes away, and then moves the service into the right state
+func (c *ReadinessCheck) Run(process *ServiceProcess) {
+	config := process.Configuration
+	retries := c.Probe.GetRetries()
+
+	for attempt := 1; attempt <= retries; attempt++ {
+		err := c.Check(config)
+
+		// Bail if the process exited or got replaced while we were probing
+		if !c.isStarting(process) {
+			return
+		}
+
+		if err == nil {
+			if c.moveOn(process, ServiceHealthy) {
+				logServiceMessage(config, "Readiness probe passed, service is healthy")
+
+				ForEachActiveUser(func(user *User) {
+					user.WriteStatusMessage(ServiceHealthy, config)
+				})
+			}
+			return
+		}
+
+		Debug.Println("Readiness probe attempt", attempt, "failed for", config.Name+":", err)
+
+		// There's no point waiting around after the last attempt
+		if attempt < retries {
+			time.Sleep(c.Probe.GetInterval())
+		}
+	}
+
+	if c.moveOn(process, ServiceUnhealthy) {
+		logServiceMessage(config, fmt.Sprintf("Readiness probe failed after %d attempts", retries))
+
+		ForEachActiveUser(func(user *User) {
+			user.WriteStatusMessage(ServiceUnhealthy, config)
+		})
+	}
+}
+
+// isStarting returns whether the process is still the service's running one,
+// and the service is still waiting on the probe
+func (c *ReadinessCheck) isStarting(process *ServiceProcess) bool {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	config := process.Configuration
+	return process.Running && config.Process == process && config.State == ServiceStarting
+}
+
+// moveOn moves the service out of the starting state and into the given one,
+// as long as the process is still the one the probe was waiting on
+func (c *ReadinessCheck) moveOn(process *ServiceProcess, state string) bool {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	config := process.Configuration
+	if !process.Running || config.Process != process || config.State != ServiceStarting {
+		return false
+	}
+
+	config.State = state
+	return true
+}
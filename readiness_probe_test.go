@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadinessProbeValidate(t *testing.T) {
+	tests := []struct {
+		probe *ReadinessProbe
+		valid bool
+	}{
+		{&ReadinessProbe{Type: ProbeTypeCommand, Command: "true"}, true},
+		{&ReadinessProbe{Type: ProbeTypeHTTP, Target: "http://localhost"}, true},
+		{&ReadinessProbe{Type: ProbeTypeOutput, Pattern: "ready"}, true},
+		{&ReadinessProbe{Type: ProbeTypeOutput, Pattern: "(ready"}, false},
+		{&ReadinessProbe{Type: ProbeTypeTCP, Target: "localhost:80"}, true},
+		{&ReadinessProbe{Type: "udp"}, false},
+		{&ReadinessProbe{}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.probe.Validate(); (err == nil) != test.valid {
+			t.Errorf("Expected %+v to be valid: %t, got %v", test.probe, test.valid, err)
+		}
+	}
+
+	service := &RunnableServiceConfiguration{}
+	err := service.Update(map[string]interface{}{"readiness": map[string]interface{}{"type": "udp"}})
+	if err != ErrorProbeUnknownType || service.Readiness != nil {
+		t.Errorf("Expected the update to be rejected, got %v", err)
+	}
+}
+
+func TestReadinessRunGivesUpAfterLastAttempt(t *testing.T) {
+	// Grab a port and give it straight back, so nothing is listening on it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := listener.Addr().String()
+	listener.Close()
+
+	probe := &ReadinessProbe{Type: ProbeTypeTCP, Target: target, Interval: 1, Retries: 2}
+	check, err := NewReadinessCheck(probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &RunnableServiceConfiguration{Name: "probe", State: ServiceStarting}
+	process := &ServiceProcess{Configuration: config, Running: true}
+	config.Process = process
+
+	// Two attempts only have the one interval between them
+	started := time.Now()
+	check.Run(process)
+	if elapsed := time.Since(started); elapsed >= 2*time.Second {
+		t.Errorf("Expected the probe to give up straight after the last attempt, took %s", elapsed)
+	}
+
+	if state := config.getState(); state != ServiceUnhealthy {
+		t.Errorf("Expected the service to be unhealthy, it was %s", state)
+	}
+}
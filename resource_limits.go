@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CgroupCPUPeriod the cgroup CPU accounting period, in microseconds, that
+	// CPU quotas are measured against
+	CgroupCPUPeriod = 100000
+
+	// CgroupDefaultRoot the cgroup that every limited service gets a child
+	// group under, unless the server is configured otherwise
+	CgroupDefaultRoot = "/sys/fs/cgroup/orchestra"
+
+	// CgroupMount where the cgroup v2 hierarchy is expected to be mounted
+	CgroupMount = "/sys/fs/cgroup"
+
+	// LimitsWrapperArg the argument orchestra is run with to act as the
+	// limits wrapper, which sets the rlimits of a service before exec'ing it
+	LimitsWrapperArg = "__orchestra_limits"
+
+	// LimitMemory the memory limit, as reported in the run history when it
+	// is what brought the service down
+	LimitMemory = "memory"
+)
+
+var (
+	// CgroupControllers the controllers that limited services make use of
+	CgroupControllers = []string{"cpu", "memory", "pids"}
+)
+
+// ResourceLimits the limits applied to a service's processes. MaxMemory is
+// in megabytes and CPUQuota is in CPUs, so 1.5 is one and a half cores
+type ResourceLimits struct {
+	CPUQuota     float64 `json:"cpu_quota,omitempty"`
+	MaxMemory    int64   `json:"max_memory,omitempty"`
+	MaxOpenFiles uint64  `json:"max_open_files,omitempty"`
+	MaxProcesses uint64  `json:"max_processes,omitempty"`
+}
+
+// NeedsCgroup returns whether any of the limits can only be enforced by a
+// cgroup
+func (r *ResourceLimits) NeedsCgroup() bool {
+	return r.CPUQuota > 0 || r.MaxMemory > 0 || r.MaxProcesses > 0
+}
+
+// Validate makes sure none of the limits are nonsensical
+func (r *ResourceLimits) Validate() error {
+	if r.CPUQuota < 0 || r.MaxMemory < 0 {
+		return ErrorInvalidLimits
+	}
+
+	return nil
+}
+
+// getCgroupRoot returns the cgroup that services get their child groups in
+func getCgroupRoot() string {
+	if Config != nil && Config.Server.CgroupRoot != "" {
+		return Config.Server.CgroupRoot
+	}
+
+	return CgroupDefaultRoot
+}
+
+// writeCgroupFile writes a single value into one of a cgroup's files
+func writeCgroupFile(dir, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// createCgroup creates a cgroup v2 child group with the given limits,
+// returning the path of the new group for the process to be started in
+func createCgroup(name string, limits *ResourceLimits) (string, error) {
+	if _, err := os.Stat(filepath.Join(CgroupMount, "cgroup.controllers")); err != nil {
+		return "", ErrorCgroupsUnavailable
+	}
+
+	root := getCgroupRoot()
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+
+	// Controllers have to be handed down a level at a time. Some of these
+	// may already be on (or unavailable), and writing the limit files below
+	// is what tells us whether we actually got what we need
+	for _, dir := range []string{filepath.Dir(root), root} {
+		for _, controller := range CgroupControllers {
+			writeCgroupFile(dir, "cgroup.subtree_control", "+"+controller)
+		}
+	}
+
+	path := filepath.Join(root, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return "", err
+	}
+
+	settings := make(map[string]string)
+	if limits.MaxMemory > 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.MaxMemory*1024*1024, 10)
+	}
+
+	if limits.CPUQuota > 0 {
+		settings["cpu.max"] = fmt.Sprintf("%d %d", int64(limits.CPUQuota*CgroupCPUPeriod), CgroupCPUPeriod)
+	}
+
+	if limits.MaxProcesses > 0 {
+		settings["pids.max"] = strconv.FormatUint(limits.MaxProcesses, 10)
+	}
+
+	for _, file := range []string{"memory.max", "cpu.max", "pids.max"} {
+		if value, ok := settings[file]; ok {
+			if err := writeCgroupFile(path, file, value); err != nil {
+				os.Remove(path)
+				return "", fmt.Errorf("Could not set %s: %s", file, err)
+			}
+		}
+	}
+
+	// Going over the limit should get the service killed rather than pushed
+	// out into swap, but not every kernel has swap accounting
+	if limits.MaxMemory > 0 {
+		writeCgroupFile(path, "memory.swap.max", "0")
+	}
+
+	return path, nil
+}
+
+// cgroupOOMKilled tests whether the kernel's OOM killer fired inside the
+// given cgroup
+func cgroupOOMKilled(path string) bool {
+	file, err := os.Open(filepath.Join(path, "memory.events"))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, _ := strconv.Atoi(fields[1])
+			return count > 0
+		}
+	}
+
+	return false
+}
+
+// prepareLimits puts the resource limits in place for the command before
+// it's started, so that nothing it forks can slip out from under them. A
+// cgroup is preferred for memory and processes, since the rlimits are poor
+// stand-ins, but they are still used when there's no cgroup. The returned
+// cgroup directory, if any, has to stay open until the command has started
+func (s *ServiceProcess) prepareLimits(command *exec.Cmd, limits *ResourceLimits) *os.File {
+	var dir *os.File
+	fallback := false
+
+	if limits.NeedsCgroup() {
+		path, err := createCgroup(fmt.Sprintf("%s-%d", s.Configuration.ID, time.Now().UnixNano()), limits)
+		if err == nil {
+			if dir, err = startInCgroup(command, path); err != nil {
+				os.Remove(path)
+			}
+		}
+
+		if err != nil {
+			fallback = true
+			logServiceMessage(s.Configuration, "Could not set up a cgroup, falling back on rlimits "+
+				"and leaving the CPU quota unenforced: "+err.Error())
+		} else {
+			s.Cgroup = path
+		}
+	}
+
+	if err := wrapRlimits(command, limits, fallback); err != nil {
+		logServiceMessage(s.Configuration, "Could not apply the resource limits: "+err.Error())
+	}
+
+	return dir
+}
+
+// releaseCgroup checks why the process in the cgroup went away, then cleans
+// the cgroup up. It returns the limit that brought the process down, if any
+func (s *ServiceProcess) releaseCgroup() string {
+	if s.Cgroup == "" {
+		return ""
+	}
+
+	limit := ""
+	if cgroupOOMKilled(s.Cgroup) {
+		limit = LimitMemory
+	}
+
+	// Anything that escaped the process group can keep the cgroup busy
+	if err := os.Remove(s.Cgroup); err != nil {
+		Debug.Println("Could not remove the cgroup for", s.Configuration.Name+":", err)
+	}
+
+	return limit
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResourceLimits(t *testing.T) {
+	tests := []struct {
+		limits ResourceLimits
+		valid  bool
+		cgroup bool
+	}{
+		{ResourceLimits{}, true, false},
+		{ResourceLimits{MaxOpenFiles: 64}, true, false},
+		{ResourceLimits{MaxProcesses: 50}, true, true},
+		{ResourceLimits{MaxMemory: 256}, true, true},
+		{ResourceLimits{CPUQuota: 1.5}, true, true},
+		{ResourceLimits{CPUQuota: -1}, false, false},
+		{ResourceLimits{MaxMemory: -1}, false, false},
+	}
+
+	for _, test := range tests {
+		if err := test.limits.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v: expected valid to be %t, got %v", test.limits, test.valid, err)
+		}
+
+		if test.valid && test.limits.NeedsCgroup() != test.cgroup {
+			t.Errorf("%+v: expected needing a cgroup to be %t", test.limits, test.cgroup)
+		}
+	}
+}
+
+func TestCgroupOOMKilled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if cgroupOOMKilled(dir) {
+		t.Error("Expected a cgroup without memory events not to count as OOM killed")
+	}
+
+	events := filepath.Join(dir, "memory.events")
+	ioutil.WriteFile(events, []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 0\n"), 0644)
+	if cgroupOOMKilled(dir) {
+		t.Error("Expected no OOM kills to be reported")
+	}
+
+	ioutil.WriteFile(events, []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644)
+	if !cgroupOOMKilled(dir) {
+		t.Error("Expected the OOM kill to be reported")
+	}
+}
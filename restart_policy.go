@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// RestartAlways restarts the service whenever it exits on its own
+	RestartAlways = "always"
+
+	// RestartNever never restarts the service
+	RestartNever = "never"
+
+	// RestartOnFailure restarts the service only when it dies
+	RestartOnFailure = "on-failure"
+
+	// RestartDefaultBackoff the default number of seconds to wait before the
+	// first restart attempt
+	RestartDefaultBackoff = 1
+
+	// RestartDefaultCrashLoopCount the default number of failures within the
+	// crash loop window that parks a service
+	RestartDefaultCrashLoopCount = 5
+
+	// RestartDefaultCrashLoopWindow the default number of seconds that
+	// failures are counted over
+	RestartDefaultCrashLoopWindow = 60
+
+	// RestartDefaultMaxBackoff the default cap, in seconds, on the time
+	// between restart attempts
+	RestartDefaultMaxBackoff = 60
+)
+
+// RestartPolicy the configuration for automatically restarting a service
+// once it exits. All of the durations are in seconds, and a MaxRetries of
+// zero means there is no limit
+type RestartPolicy struct {
+	Backoff         int    `json:"backoff,omitempty"`
+	CrashLoopCount  int    `json:"crash_loop_count,omitempty"`
+	CrashLoopWindow int    `json:"crash_loop_window,omitempty"`
+	MaxBackoff      int    `json:"max_backoff,omitempty"`
+	MaxRetries      int    `json:"max_retries,omitempty"`
+	Policy          string `json:"policy"`
+}
+
+// GetBackoff returns how long to wait before the given (zero based) attempt
+func (r *RestartPolicy) GetBackoff(attempt int) time.Duration {
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = RestartDefaultBackoff
+	}
+
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = RestartDefaultMaxBackoff
+	}
+
+	delay := backoff
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	return time.Duration(delay) * time.Second
+}
+
+// GetCrashLoopCount returns how many failures within the window are tolerated
+func (r *RestartPolicy) GetCrashLoopCount() int {
+	if r.CrashLoopCount <= 0 {
+		return RestartDefaultCrashLoopCount
+	}
+
+	return r.CrashLoopCount
+}
+
+// GetCrashLoopWindow returns the window failures are counted over
+func (r *RestartPolicy) GetCrashLoopWindow() time.Duration {
+	if r.CrashLoopWindow <= 0 {
+		return RestartDefaultCrashLoopWindow * time.Second
+	}
+
+	return time.Duration(r.CrashLoopWindow) * time.Second
+}
+
+// Validate makes sure the policy is one we know about. Leaving it out is the
+// same as never
+func (r *RestartPolicy) Validate() error {
+	switch r.Policy {
+	case "", RestartAlways, RestartNever, RestartOnFailure:
+		return nil
+	}
+
+	return ErrorUnknownRestartPolicy
+}
+
+// ShouldRestart returns whether an exit should trigger a restart under
+// this policy
+func (r *RestartPolicy) ShouldRestart(failed bool) bool {
+	switch r.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return failed
+	}
+
+	return false
+}
+
+// cancelRestart stops any pending restart attempt
+func (s *RunnableServiceConfiguration) cancelRestart() bool {
+	if s.RestartTimer != nil {
+		cancelled := s.RestartTimer.Stop()
+		s.RestartTimer = nil
+
+		return cancelled
+	}
+
+	return false
+}
+
+// handleExit decides what to do once the process for this service has exited
+// on its own, scheduling a restart if the restart policy calls for one
+func (s *RunnableServiceConfiguration) handleExit(reason string) {
+	policy := s.RestartPolicy
+	state := s.getState()
+	failed := state == ServiceDead || state == ServiceOOMKilled
+	if policy == nil || !policy.ShouldRestart(failed) {
+		return
+	}
+
+	// Only hold on to the exits that are still inside the crash loop window
+	now := time.Now()
+	window := policy.GetCrashLoopWindow()
+	recent := []time.Time{now}
+	for _, exit := range s.RecentExits {
+		if now.Sub(exit) < window {
+			recent = append(recent, exit)
+		}
+	}
+	s.RecentExits = recent
+
+	if len(recent) >= policy.GetCrashLoopCount() {
+		state = ServiceCrashLoop
+		s.setState(state)
+		logServiceMessage(s, fmt.Sprintf("Process exited (%s) %d times within %s, "+
+			"not restarting until it is started again", reason, len(recent), window))
+	} else if policy.MaxRetries > 0 && s.Restarts >= policy.MaxRetries {
+		logServiceMessage(s, fmt.Sprintf("Process exited (%s), giving up after %d restart attempts",
+			reason, s.Restarts))
+		return
+	} else {
+		delay := policy.GetBackoff(s.Restarts)
+		s.Restarts++
+		state = ServiceRestarting
+		s.setState(state)
+
+		attempt := fmt.Sprint(s.Restarts)
+		if policy.MaxRetries > 0 {
+			attempt = fmt.Sprintf("%d/%d", s.Restarts, policy.MaxRetries)
+		}
+
+		logServiceMessage(s, fmt.Sprintf("Process exited (%s), restarting in %s (attempt %s)",
+			reason, delay, attempt))
+
+		var timer *time.Timer
+		timer = time.AfterFunc(delay, func() {
+			// Make sure nobody cancelled or superseded us in the meantime
+			if s.RestartTimer != timer || s.isRunning() {
+				return
+			}
+
+			s.RestartTimer = nil
+			logServiceMessage(s, "Restart attempt "+attempt)
+
+			// Something may have grabbed a port since the last start
+			if err := s.assignPorts(); err != nil {
+				s.failStart(NewServiceRun(TriggerRestart, "", s.Restarts), err)
+				return
+			}
+
+			s.start(TriggerRestart, "")
+		})
+		s.RestartTimer = timer
+	}
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(state, s)
+	})
+}
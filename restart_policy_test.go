@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRestartPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy   string
+		expected error
+	}{
+		{"", nil},
+		{RestartNever, nil},
+		{RestartOnFailure, nil},
+		{RestartAlways, nil},
+		{"on_failure", ErrorUnknownRestartPolicy},
+		{"sometimes", ErrorUnknownRestartPolicy},
+	}
+
+	for _, test := range tests {
+		policy := &RestartPolicy{Policy: test.policy}
+		if err := policy.Validate(); err != test.expected {
+			t.Errorf("Expected %q to give %v, got %v", test.policy, test.expected, err)
+		}
+	}
+
+	service := &RunnableServiceConfiguration{}
+	err := service.Update(map[string]interface{}{"restart_policy": map[string]interface{}{"policy": "on_failure"}})
+	if err != ErrorUnknownRestartPolicy || service.RestartPolicy != nil {
+		t.Errorf("Expected the update to be rejected, got %v", err)
+	}
+}
@@ -1,49 +1,180 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"log"
 	"os"
 	"os/exec"
+	"sync"
+	"syscall"
+	"time"
 )
 
 const (
+	// LaunchModeExec launches the service's argv directly, without a shell
+	LaunchModeExec = "exec"
+
+	// LaunchModeShell launches a shell and feeds it the service's commands
+	// over stdin
+	LaunchModeShell = "shell"
+
 	// ServiceDefaultHistoryLimit is the constant that defines just how
 	// many historical lines to retain so when a new consumer jumps on
 	// it gets at least some of the logging history
 	ServiceDefaultHistoryLimit = 200
 
+	// ServiceDefaultPtyCols the default width of a service's terminal
+	ServiceDefaultPtyCols = 80
+
+	// ServiceDefaultPtyRows the default height of a service's terminal
+	ServiceDefaultPtyRows = 24
+
+	// ServiceDefaultStopGracePeriod the default number of seconds a service
+	// gets to exit after its stop signal before it is killed
+	ServiceDefaultStopGracePeriod = 10
+
+	// ServiceDefaultStopSignal the default signal used to ask a service to
+	// shut down
+	ServiceDefaultStopSignal = "SIGTERM"
+
+	// ServiceExitTimeout how long a restart waits, once the service has been
+	// stopped, for the exit listener to confirm the old process is gone
+	ServiceExitTimeout = 5 * time.Second
+
 	// ServiceDead when the service is dead
 	ServiceDead = "dead"
 
 	// ServiceRunning the service is running
 	ServiceRunning = "running"
 
+	// ServiceCrashLoop the service kept dying and its restart policy has
+	// given up on it
+	ServiceCrashLoop = "crash_loop"
+
+	// ServiceDegraded the service is running but its output set off one of
+	// its alert rules
+	ServiceDegraded = "degraded"
+
 	// ServiceFailed the service has failed and is dead
 	ServiceFailed = "failed"
 
+	// ServiceHealthy the service is running and its readiness probe passed
+	ServiceHealthy = "healthy"
+
+	// ServiceOOMKilled the service was killed for going over its memory
+	// limit
+	ServiceOOMKilled = "oom_killed"
+
+	// ServicePending the task hasn't finished a run yet
+	ServicePending = "pending"
+
+	// ServiceRestarting the service exited and is waiting to be restarted by
+	// its restart policy
+	ServiceRestarting = "restarting"
+
+	// ServiceScheduled the service is waiting for its next scheduled run
+	ServiceScheduled = "scheduled"
+
+	// ServiceStarting the service is running but its readiness probe hasn't
+	// passed yet
+	ServiceStarting = "starting"
+
+	// ServiceSucceeded the task ran to completion and exited cleanly
+	ServiceSucceeded = "succeeded"
+
 	// ServiceStopped the service is not currently running and is
 	// also not in an error condition
 	ServiceStopped = "stopped"
+
+	// ServiceWaiting the service is waiting out its delay before being
+	// started
+	ServiceWaiting = "waiting"
+
+	// ServiceUnhealthy the service is running but its readiness probe gave
+	// up on it
+	ServiceUnhealthy = "unhealthy"
 )
 
+// serviceStateMutex guards how far along each runnable service is, ie its
+// State, Running and Process along with the Running and Stopping flags of
+// the process. The process's own goroutines move these along just as much
+// as whoever is managing the service does. Replicas get copied from their
+// service wholesale, so the one lock covers every service
+var serviceStateMutex sync.RWMutex
+
+// IsActiveState returns whether the given state is one where the service
+// has something live behind it, be it a process or a schedule
+func IsActiveState(state string) bool {
+	switch state {
+	case ServiceDegraded, ServiceHealthy, ServiceRunning, ServiceScheduled, ServiceStarting, ServiceUnhealthy:
+		return true
+	}
+
+	return false
+}
+
+// IsReadyState returns whether the given state is one where the service can
+// actually take traffic. A scheduled job counts, since it's as up as it gets
+// in between runs
+func IsReadyState(state string) bool {
+	return state == ServiceRunning || state == ServiceHealthy || state == ServiceScheduled
+}
+
 // RunnableServiceConfiguration the struct for storing a particular configuration
 type RunnableServiceConfiguration struct {
-	Branch      string                `json:"branch"`
-	Commands    []string              `json:"commands"`
-	Description string                `json:"description"`
-	DelayAfter  int                   `json:"delay_after"`
-	DelayBefore int                   `json:"delay_before"`
-	ID          string                `json:"id"`
-	Name        string                `json:"name"`
-	Process     *ServiceProcess       `json:"-"`
-	Project     *ProjectConfiguration `json:"-"`
-	Repository  string                `json:"repository,omitempty"`
-	Running     bool                  `json:"-"`
-	State       string                `json:"-"`
-	Type        string                `json:"type"`
-	WorkingDir  string                `json:"working_dir"`
+	Alerts          []*AlertRule                    `json:"alerts,omitempty"`
+	AllocatedPorts  map[string]int                  `json:"-"`
+	AllowedSignals  []string                        `json:"allowed_signals,omitempty"`
+	Argv            []string                        `json:"argv,omitempty"`
+	Branch          string                          `json:"branch"`
+	CheckoutBranch  bool                            `json:"checkout_branch,omitempty"`
+	CleanEnv        bool                            `json:"clean_env,omitempty"`
+	Commands        []string                        `json:"commands"`
+	Description     string                          `json:"description"`
+	DelayAfter      int                             `json:"delay_after"`
+	DelayBefore     int                             `json:"delay_before"`
+	DependsOn       []string                        `json:"depends_on,omitempty"`
+	Detached        bool                            `json:"detached,omitempty"`
+	Env             map[string]string               `json:"env,omitempty"`
+	EnvFiles        []string                        `json:"env_files,omitempty"`
+	History         *ServiceHistory                 `json:"-"`
+	ID              string                          `json:"id"`
+	Index           int                             `json:"-"`
+	Instances       []*RunnableServiceConfiguration `json:"-"`
+	LaunchMode      string                          `json:"launch_mode,omitempty"`
+	Limits          *ResourceLimits                 `json:"limits,omitempty"`
+	Logs            *ServiceLog                     `json:"-"`
+	Name            string                          `json:"name"`
+	Parent          *RunnableServiceConfiguration   `json:"-"`
+	Ports           []*ServicePort                  `json:"ports,omitempty"`
+	PostStart       []string                        `json:"post_start,omitempty"`
+	PostStop        []string                        `json:"post_stop,omitempty"`
+	PreStart        []string                        `json:"pre_start,omitempty"`
+	PreStop         []string                        `json:"pre_stop,omitempty"`
+	Process         *ServiceProcess                 `json:"-"`
+	Project         *ProjectConfiguration           `json:"-"`
+	Pty             bool                            `json:"pty,omitempty"`
+	PtyCols         int                             `json:"pty_cols,omitempty"`
+	PtyRows         int                             `json:"pty_rows,omitempty"`
+	Readiness       *ReadinessProbe                 `json:"readiness,omitempty"`
+	RecentExits     []time.Time                     `json:"-"`
+	Replicas        int                             `json:"replicas,omitempty"`
+	Repository      string                          `json:"repository,omitempty"`
+	Requester       string                          `json:"-"`
+	RestartPolicy   *RestartPolicy                  `json:"restart_policy,omitempty"`
+	RestartTimer    *time.Timer                     `json:"-"`
+	Restarts        int                             `json:"-"`
+	Running         bool                            `json:"-"`
+	Shell           string                          `json:"shell,omitempty"`
+	ShellArgs       []string                        `json:"shell_args,omitempty"`
+	State           string                          `json:"-"`
+	StopGracePeriod int                             `json:"stop_grace_period,omitempty"`
+	StopSignal      string                          `json:"stop_signal,omitempty"`
+	Trigger         string                          `json:"-"`
+	Type            string                          `json:"type"`
+	Watch           *WatchConfiguration             `json:"watch,omitempty"`
+	Watcher         *FileWatcher                    `json:"-"`
+	WorkingDir      string                          `json:"working_dir"`
 }
 
 func (s *RunnableServiceConfiguration) Accept(src interface{}) bool {
@@ -72,46 +203,255 @@ func (s *RunnableServiceConfiguration) Create(newConfig map[string]interface{},
 	return runnable
 }
 
+// Fail flags the service as failed and records the reason in its log
+func (s *RunnableServiceConfiguration) Fail(reason error) {
+	Error.Println(s.Name, "failed:", reason)
+
+	s.setRunState(false, ServiceFailed)
+	logServiceMessage(s, reason.Error())
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(ServiceFailed, s)
+	})
+}
+
 // GenerateID generates a new ID
 func (s *RunnableServiceConfiguration) GenerateID() {
 	s.ID = GenerateServiceID()
 }
 
+// GetDelayAfter returns how long the project pauses after starting or
+// stopping the service
+func (s *RunnableServiceConfiguration) GetDelayAfter() time.Duration {
+	return time.Duration(s.DelayAfter) * time.Second
+}
+
+// GetDelayBefore returns how long the project pauses before starting or
+// stopping the service
+func (s *RunnableServiceConfiguration) GetDelayBefore() time.Duration {
+	return time.Duration(s.DelayBefore) * time.Second
+}
+
+func (s *RunnableServiceConfiguration) GetDependencies() []string {
+	return s.DependsOn
+}
+
+// GetDetails returns the runtime details of the service for the API
+func (s *RunnableServiceConfiguration) GetDetails() map[string]interface{} {
+	details := make(map[string]interface{})
+
+	env, err := s.GetEnvironment()
+	if err != nil {
+		details["env_error"] = err.Error()
+	} else {
+		details["effective_env"] = maskEnv(env)
+	}
+
+	if s.Detached {
+		details["log_file"] = getLogFilePath(s.ID)
+	}
+
+	if ports := s.GetPorts(); len(ports) > 0 {
+		details["ports"] = ports
+	}
+
+	if status := s.GetRepositoryStatus(); status != nil {
+		details["repository"] = status
+	}
+
+	if process := s.runningProcess(); process != nil {
+		if stats := process.GetStats(); stats != nil {
+			details["stats"] = stats
+		}
+	}
+
+	if instances := s.getInstances(); len(instances) > 0 {
+		replicas := []map[string]interface{}{}
+		for _, replica := range append([]*RunnableServiceConfiguration{s}, instances...) {
+			replicas = append(replicas, map[string]interface{}{
+				"id":    replica.ID,
+				"index": replica.Index,
+				"ports": replica.GetPorts(),
+				"state": replica.getState()})
+		}
+
+		details["replicas"] = replicas
+	}
+
+	return details
+}
+
+// GetHistory returns the run history, loading it off of disk the first time
+// it's needed
+func (s *RunnableServiceConfiguration) GetHistory() *ServiceHistory {
+	if s.History == nil {
+		s.History = loadServiceHistory(s.ID)
+	}
+
+	return s.History
+}
+
 func (s *RunnableServiceConfiguration) GetID() string {
 	return s.ID
 }
 
+// GetLogs returns the service log, which is shared across every process run
+// so the history survives restarts
 func (s *RunnableServiceConfiguration) GetLogs() *ServiceLog {
-	if s.Process != nil {
-		return s.Process.Logs
+	if s.Logs == nil {
+		s.Logs = &ServiceLog{}
 	}
 
-	return nil
+	return s.Logs
+}
+
+func (s *RunnableServiceConfiguration) GetName() string {
+	return s.Name
 }
 
 func (s *RunnableServiceConfiguration) GetProject() *ProjectConfiguration {
 	return s.Project
 }
 
+// GetState returns the state of the service. A service with replicas is up
+// for as long as any of them are, even if the first one has gone down
 func (s *RunnableServiceConfiguration) GetState() string {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	if !IsActiveState(s.State) {
+		for _, replica := range s.Instances {
+			if IsActiveState(replica.State) {
+				return replica.State
+			}
+		}
+	}
+
 	return s.State
 }
 
-// GetBranch returns the current branch
-func (s *RunnableServiceConfiguration) GetBranch() string {
-	dir := s.GetWorkingDir()
-	branch := ""
-	cmd := exec.Command(`bash -c "cd ` + dir + `; git branch | grep \"*\""`)
+// getState returns the state of the service itself, leaving its replicas
+// out of it
+func (s *RunnableServiceConfiguration) getState() string {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	return s.State
+}
+
+// setState moves the service into the given state
+func (s *RunnableServiceConfiguration) setState(state string) {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	s.State = state
+}
 
-	out, err := cmd.Output()
+// isRunning returns whether the service has a process running
+func (s *RunnableServiceConfiguration) isRunning() bool {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
 
+	return s.Running
+}
+
+// setRunning flags whether the service has a process running
+func (s *RunnableServiceConfiguration) setRunning(running bool) {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	s.Running = running
+}
+
+// setRunState flags whether the service has a process running and moves it
+// into the given state in one go
+func (s *RunnableServiceConfiguration) setRunState(running bool, state string) {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	s.Running = running
+	s.State = state
+}
+
+// getProcess returns the last process started for the service, which may
+// well have exited since
+func (s *RunnableServiceConfiguration) getProcess() *ServiceProcess {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	return s.Process
+}
+
+// setProcess makes the given process the service's current one
+func (s *RunnableServiceConfiguration) setProcess(process *ServiceProcess) {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	s.Process = process
+}
+
+// runningProcess returns the process of the service, or nil if it isn't
+// running
+func (s *RunnableServiceConfiguration) runningProcess() *ServiceProcess {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	if !s.Running {
+		return nil
+	}
+
+	return s.Process
+}
+
+// GetShell returns the shell executable and its arguments for this service,
+// falling back on the server wide shell when the service doesn't set one
+func (s *RunnableServiceConfiguration) GetShell() (string, []string) {
+	if s.Shell != "" {
+		return s.Shell, s.ShellArgs
+	}
+
+	return getServerShell()
+}
+
+// GetPtySize returns the initial column and row count for the service's
+// terminal
+func (s *RunnableServiceConfiguration) GetPtySize() (int, int) {
+	cols, rows := s.PtyCols, s.PtyRows
+	if cols <= 0 {
+		cols = ServiceDefaultPtyCols
+	}
+
+	if rows <= 0 {
+		rows = ServiceDefaultPtyRows
+	}
+
+	return cols, rows
+}
+
+// GetStopGracePeriod returns how long the service gets to exit on its own
+// once it has been sent its stop signal
+func (s *RunnableServiceConfiguration) GetStopGracePeriod() time.Duration {
+	if s.StopGracePeriod <= 0 {
+		return ServiceDefaultStopGracePeriod * time.Second
+	}
+
+	return time.Duration(s.StopGracePeriod) * time.Second
+}
+
+// GetStopSignal returns the signal used to ask the service to shut down
+func (s *RunnableServiceConfiguration) GetStopSignal() syscall.Signal {
+	name := s.StopSignal
+	if name == "" {
+		name = ServiceDefaultStopSignal
+	}
+
+	sig, err := parseSignal(name)
 	if err != nil {
-		Error.Println("Error while running the command:", err)
-	} else {
-		branch = bytes.NewBuffer(out).String()
+		Error.Println("Invalid stop signal for", s.Name+", falling back to", ServiceDefaultStopSignal)
+		return syscall.SIGTERM
 	}
 
-	return branch
+	return sig
 }
 
 // GetWorkingDir returns the current working directory, either based on the
@@ -131,6 +471,12 @@ func (s *RunnableServiceConfiguration) GetWorkingDir() string {
 	return s.WorkingDir
 }
 
+// IsExecMode returns whether the service is launched directly rather than
+// through a shell
+func (s *RunnableServiceConfiguration) IsExecMode() bool {
+	return s.LaunchMode == LaunchModeExec
+}
+
 func (s *RunnableServiceConfiguration) IsMatch(config interface{}) bool {
 	var configID string
 
@@ -149,46 +495,294 @@ func (s *RunnableServiceConfiguration) IsMatch(config interface{}) bool {
 	return s.ID != "" && s.ID == configID
 }
 
-// Start starts a thing
+// ShellCommand builds a command that runs the given script through the
+// service's shell, inside its working dir and with its environment
+func (s *RunnableServiceConfiguration) ShellCommand(script string) (*exec.Cmd, error) {
+	env, err := s.GetEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	shell, args := s.GetShell()
+	cmd := exec.Command(shell, append(append([]string{}, args...), "-c", script)...)
+	cmd.Dir = s.GetWorkingDir()
+	cmd.Env = envToList(env)
+
+	return cmd, nil
+}
+
+// SetWaiting flags whether the service is waiting to be started
+func (s *RunnableServiceConfiguration) SetWaiting(waiting bool) {
+	state := ServiceWaiting
+	if !waiting {
+		state = ServiceStopped
+	}
+
+	serviceStateMutex.Lock()
+	changed := waiting || s.State == ServiceWaiting
+	if changed {
+		s.State = state
+	}
+	serviceStateMutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(state, s)
+	})
+}
+
+// SetTrigger sets what and who the next call to Start is on behalf of
+func (s *RunnableServiceConfiguration) SetTrigger(trigger, user string) {
+	s.Trigger = trigger
+	s.Requester = user
+}
+
+// Start starts a thing. Starting by hand always resets the restart policy's
+// bookkeeping, and starts watching the service's files if it wants that.
+// Every replica of the service gets started along with it
 func (s *RunnableServiceConfiguration) Start() bool {
+	trigger, user := s.Trigger, s.Requester
+	if trigger == "" {
+		trigger = TriggerManual
+	}
+	s.Trigger, s.Requester = "", ""
+
+	s.startWatching()
+	started := s.freshStart(trigger, user)
+	s.startReplicas(trigger, user)
+
+	return started
+}
+
+// freshStart starts the service with a clean slate as far as the restart
+// policy is concerned. Unlike restarts by the restart policy, this runs the
+// start hooks
+func (s *RunnableServiceConfiguration) freshStart(trigger, user string) bool {
+	s.cancelRestart()
+	s.RecentExits = nil
+	s.Restarts = 0
+
+	if err := s.prepareRepository(); err != nil {
+		s.failStart(NewServiceRun(trigger, user, s.Restarts), err)
+		return false
+	}
+
+	// The hooks get to see the ports too, so they're sorted out up front
+	if err := s.assignPorts(); err != nil {
+		s.failStart(NewServiceRun(trigger, user, s.Restarts), err)
+		return false
+	}
+
+	if err := s.runHooks(HookPreStart, s.PreStart); err != nil {
+		s.failStart(NewServiceRun(trigger, user, s.Restarts), err)
+		return false
+	}
+
+	if !s.start(trigger, user) {
+		return false
+	}
+
+	if len(s.PostStart) > 0 {
+		go s.runPostStartHooks(s.getProcess())
+	}
+
+	return true
+}
+
+// failStart flags the service as failed and records the run that never got
+// going
+func (s *RunnableServiceConfiguration) failStart(run *ServiceRun, err error) {
+	s.Fail(err)
+
+	run.Error = err.Error()
+	run.Finish(s.getState(), nil)
+	s.recordRun(run)
+}
+
+// restartForChanges restarts the service once its files have changed. The
+// service comes back even if it had died, since the change may well be the
+// fix
+func (s *RunnableServiceConfiguration) restartForChanges() bool {
+	if s.isRunning() {
+		s.stopProcess()
+	}
+
+	started := s.freshStart(TriggerFileWatch, "")
+	s.restartReplicasForChanges()
+
+	return started
+}
+
+// startWatching starts watching the service's files, if it's configured to
+// and isn't already
+func (s *RunnableServiceConfiguration) startWatching() {
+	if s.Watch == nil || s.Watcher != nil {
+		return
+	}
+
+	watcher, err := NewFileWatcher(s)
+	if err != nil {
+		logServiceMessage(s, "Could not watch for file changes: "+err.Error())
+		return
+	}
+
+	s.Watcher = watcher
+	go watcher.Run()
+	logServiceMessage(s, "Watching "+watcher.Root+" for changes")
+}
+
+// stopWatching stops watching the service's files
+func (s *RunnableServiceConfiguration) stopWatching() {
+	if s.Watcher != nil {
+		s.Watcher.Stop()
+		s.Watcher = nil
+		logServiceMessage(s, "Stopped watching for changes")
+	}
+}
+
+// start launches a new process for the service, on behalf of the given
+// trigger and user. The ports have to have been assigned beforehand
+func (s *RunnableServiceConfiguration) start(trigger, user string) bool {
 	s.WorkingDir = s.GetWorkingDir()
 
 	Info.Println("Starting up", s.Name)
-	s.Process = s.Process.Start(s)
-	s.State = ServiceRunning
 
-	for socket, user := range activeUsers {
-		if socket != nil {
-			Info.Println("Telling users about", s.Name)
-			user.ResetSubscription(s)
-			user.WriteStatusMessage(s.State, s)
-			activeUsers[socket] = user
-		}
+	// Flag the state before launching so a process that exits right away
+	// doesn't get its exit state clobbered. With a readiness probe we aren't
+	// really up until the probe says so
+	state := ServiceRunning
+	if s.Readiness != nil {
+		state = ServiceStarting
 	}
+	s.setRunState(true, state)
 
-	s.Running = true
-	return s.Running
+	run := NewServiceRun(trigger, user, s.Restarts)
+	process, err := s.getProcess().Start(s, run)
+	if err != nil {
+		s.failStart(run, err)
+		return false
+	}
+
+	s.setProcess(process)
+
+	ForEachActiveUser(func(user *User) {
+		Info.Println("Telling users about", s.Name)
+		user.ResetSubscription(s)
+		user.WriteStatusMessage(s.getState(), s)
+	})
+
+	if process.Readiness != nil {
+		go process.Readiness.Run(process)
+	}
+
+	return true
 }
 
-// Stop stops the service configuration if it's running
+// Stop stops the service configuration if it's running, along with every
+// one of its replicas
 func (s *RunnableServiceConfiguration) Stop() bool {
-	if s.Running {
-		s.Process.Kill()
-		s.Running = false
+	s.stopWatching()
+	stopped := stopReplicas(s.getInstances())
+
+	// A pending restart counts as running as far as the user is concerned
+	if s.cancelRestart() && !s.isRunning() {
+		releasePorts(s.ID)
+		s.setState(ServiceStopped)
+		logServiceMessage(s, "Pending restart was cancelled")
+
+		ForEachActiveUser(func(user *User) {
+			user.WriteStatusMessage(ServiceStopped, s)
+		})
+		return true
+	}
 
+	if s.isRunning() {
+		s.stopProcess()
+		releasePorts(s.ID)
 		return true
 	}
 
-	log.Println("Stop called on a non-running service configuration")
-	return false
+	if !stopped {
+		log.Println("Stop called on a non-running service configuration")
+	}
+
+	return stopped
+}
+
+// Restart stops the service and starts it again once its old processes are
+// gone for good
+func (s *RunnableServiceConfiguration) Restart() bool {
+	return s.restart(s.Stop, s.Start)
+}
+
+// restart stops the service, waits for the old processes to exit, and then
+// starts it again. Types built on top of runnable services stop and start
+// in their own way, so they pass those along
+func (s *RunnableServiceConfiguration) restart(stop, start func() bool) bool {
+	logServiceMessage(s, "Restarting")
+	stop()
+
+	if !s.AwaitExit() {
+		logServiceMessage(s, "Not restarting, the old process is still running")
+		return false
+	}
+
+	return start()
+}
+
+// AwaitExit waits for the exit listeners to confirm that the last process of
+// the service, and of each of its replicas, has exited. A process that's
+// been killed can take a moment to be reaped, so this gives up only after
+// ServiceExitTimeout
+func (s *RunnableServiceConfiguration) AwaitExit() bool {
+	timeout := time.After(ServiceExitTimeout)
+
+	for _, instance := range append([]*RunnableServiceConfiguration{s}, s.getInstances()...) {
+		process := instance.getProcess()
+		if process == nil {
+			continue
+		}
+
+		select {
+		case <-process.Exited:
+		case <-timeout:
+			return false
+		}
+	}
+
+	return true
+}
+
+// stopProcess stops the running process, with the stop hooks on either side
+// of it. Failing stop hooks don't stop the service from being stopped
+func (s *RunnableServiceConfiguration) stopProcess() {
+	if err := s.runHooks(HookPreStop, s.PreStop); err != nil {
+		logServiceMessage(s, err.Error())
+	}
+
+	s.getProcess().Stop(s.GetStopSignal(), s.GetStopGracePeriod())
+	s.setRunning(false)
+
+	if err := s.runHooks(HookPostStop, s.PostStop); err != nil {
+		logServiceMessage(s, err.Error())
+	}
 }
 
 // Update updates the service configuration
 func (s *RunnableServiceConfiguration) Update(newConfig map[string]interface{}) error {
-	if s.Running {
+	// Replicas can outlive the first one, so they count too
+	if s.isRunning() || IsActiveState(s.GetState()) {
 		return ErrorCannotModifyService
 	}
 
+	// A replica gets its configuration from its service every time it starts
+	if s.IsReplica() {
+		return ErrorCannotModifyReplica
+	}
+
 	data, err := json.Marshal(newConfig)
 	if err != nil {
 		Error.Println("Error while loading new runnable service:", err)
@@ -206,6 +800,10 @@ func (s *RunnableServiceConfiguration) Update(newConfig map[string]interface{})
 	s.DelayBefore = shimService.DelayBefore
 	s.Description = shimService.Description
 
+	if shimService.DependsOn != nil {
+		s.DependsOn = shimService.DependsOn
+	}
+
 	if shimService.Name != "" {
 		s.Name = shimService.Name
 	}
@@ -214,14 +812,171 @@ func (s *RunnableServiceConfiguration) Update(newConfig map[string]interface{})
 		s.Branch = shimService.Branch
 	}
 
+	if _, ok := newConfig["checkout_branch"]; ok {
+		s.CheckoutBranch = shimService.CheckoutBranch
+	}
+
 	if shimService.Commands != nil {
 		s.Commands = shimService.Commands
 	}
 
+	if shimService.Alerts != nil {
+		if _, err := NewAlertCheck(shimService.Alerts); err != nil {
+			return err
+		}
+
+		s.Alerts = shimService.Alerts
+	}
+
+	if shimService.AllowedSignals != nil {
+		if err := validateSignals(shimService.AllowedSignals); err != nil {
+			return err
+		}
+
+		s.AllowedSignals = shimService.AllowedSignals
+	}
+
+	if shimService.Argv != nil {
+		s.Argv = shimService.Argv
+	}
+
+	if shimService.LaunchMode != "" {
+		if shimService.LaunchMode != LaunchModeExec && shimService.LaunchMode != LaunchModeShell {
+			return ErrorUnknownLaunchMode
+		}
+
+		s.LaunchMode = shimService.LaunchMode
+	}
+
+	if _, ok := newConfig["shell"]; ok {
+		s.Shell = shimService.Shell
+	}
+
+	if shimService.ShellArgs != nil {
+		s.ShellArgs = shimService.ShellArgs
+	}
+
+	if _, ok := newConfig["detached"]; ok {
+		s.Detached = shimService.Detached
+	}
+
+	if _, ok := newConfig["pty"]; ok {
+		s.Pty = shimService.Pty
+	}
+
+	if shimService.PtyCols != 0 {
+		s.PtyCols = shimService.PtyCols
+	}
+
+	if shimService.PtyRows != 0 {
+		s.PtyRows = shimService.PtyRows
+	}
+
+	if _, ok := newConfig["clean_env"]; ok {
+		s.CleanEnv = shimService.CleanEnv
+	}
+
+	if shimService.Env != nil {
+		restoreMaskedEnv(s.Env, shimService.Env)
+		s.Env = shimService.Env
+	}
+
+	if shimService.EnvFiles != nil {
+		s.EnvFiles = shimService.EnvFiles
+	}
+
+	if shimService.Limits != nil {
+		if err := shimService.Limits.Validate(); err != nil {
+			return err
+		}
+
+		s.Limits = shimService.Limits
+	}
+
+	if shimService.PostStart != nil {
+		s.PostStart = shimService.PostStart
+	}
+
+	if shimService.PostStop != nil {
+		s.PostStop = shimService.PostStop
+	}
+
+	if shimService.PreStart != nil {
+		s.PreStart = shimService.PreStart
+	}
+
+	if shimService.PreStop != nil {
+		s.PreStop = shimService.PreStop
+	}
+
+	ports := s.Ports
+	if shimService.Ports != nil {
+		if err := validatePorts(shimService.Ports); err != nil {
+			return err
+		}
+
+		ports = shimService.Ports
+	}
+
+	// Every replica offsets the fixed ports by its index, so new ports on a
+	// service that's already replicated have to fit all of them too
+	replicas := s.GetReplicas()
+	if shimService.Replicas != 0 {
+		replicas = shimService.Replicas
+	}
+
+	if err := validateReplicas(replicas, ports); err != nil {
+		return err
+	}
+
+	s.Ports = ports
+
+	serviceStateMutex.Lock()
+	if shimService.Replicas != 0 {
+		s.Replicas = shimService.Replicas
+	}
+
+	if len(s.Instances) >= s.replicaCount() {
+		s.Instances = s.Instances[:s.replicaCount()-1]
+	}
+	serviceStateMutex.Unlock()
+
+	if shimService.Readiness != nil {
+		if err := shimService.Readiness.Validate(); err != nil {
+			return err
+		}
+
+		s.Readiness = shimService.Readiness
+	}
+
+	if shimService.StopSignal != "" {
+		if _, err := parseSignal(shimService.StopSignal); err != nil {
+			return err
+		}
+
+		s.StopSignal = shimService.StopSignal
+	}
+
+	if shimService.StopGracePeriod != 0 {
+		s.StopGracePeriod = shimService.StopGracePeriod
+	}
+
+	if shimService.RestartPolicy != nil {
+		if err := shimService.RestartPolicy.Validate(); err != nil {
+			return err
+		}
+
+		s.RestartPolicy = shimService.RestartPolicy
+	}
+
 	if shimService.Repository != "" {
 		s.Repository = shimService.Repository
 	}
 
+	if shimService.Watch != nil {
+		s.Watch = shimService.Watch
+	}
+
 	if shimService.WorkingDir != "" {
 		s.WorkingDir = shimService.WorkingDir
 	}
@@ -234,3 +989,35 @@ func (s *RunnableServiceConfiguration) Update(newConfig map[string]interface{})
 	s.Type = TypeRunnableService
 	return nil
 }
+
+// Resize changes the window size of the service's terminal
+func (s *RunnableServiceConfiguration) Resize(cols, rows int) error {
+	process := s.runningProcess()
+	if process == nil {
+		return ErrorServiceNotRunning
+	}
+
+	return process.Resize(cols, rows)
+}
+
+// WriteInput sends the given data to the stdin of the running service
+func (s *RunnableServiceConfiguration) WriteInput(data []byte) error {
+	process := s.runningProcess()
+	if process == nil {
+		return ErrorServiceNotRunning
+	}
+
+	if process.LogPath != "" {
+		return ErrorServiceDetached
+	}
+
+	if process.Adopted {
+		return ErrorServiceAdopted
+	}
+
+	if !process.Write(data) {
+		return ErrorCannotWriteInput
+	}
+
+	return nil
+}
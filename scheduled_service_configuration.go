@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// OverlapKill a run that comes due while the last one is still going
+	// stops the last one
+	OverlapKill = "kill"
+
+	// OverlapQueue a run that comes due while the last one is still going
+	// waits for it to finish. Only the one run is ever queued up
+	OverlapQueue = "queue"
+
+	// OverlapSkip a run that comes due while the last one is still going is
+	// skipped
+	OverlapSkip = "skip"
+
+	// ScheduleTimeFormat how run times are written into the service log
+	ScheduleTimeFormat = "2006-01-02 15:04:05"
+)
+
+// ScheduledServiceConfiguration a job that gets run on a schedule, which is
+// either a cron expression or a fixed interval in seconds. Each run is
+// launched exactly like a runnable service, so everything about the command,
+// working dir and environment works the same way
+type ScheduledServiceConfiguration struct {
+	RunnableServiceConfiguration
+
+	Cron      string        `json:"cron,omitempty"`
+	Interval  int           `json:"interval,omitempty"`
+	Mutex     sync.Mutex    `json:"-"`
+	NextRun   time.Time     `json:"-"`
+	Overlap   string        `json:"overlap,omitempty"`
+	Queued    bool          `json:"-"`
+	Scheduler chan struct{} `json:"-"`
+	Type      string        `json:"type"`
+}
+
+// validateSchedule makes sure there's exactly one schedule and that it and
+// the overlap policy make sense
+func validateSchedule(cron string, interval int, overlap string) error {
+	if (cron == "") == (interval <= 0) {
+		return ErrorInvalidSchedule
+	}
+
+	if cron != "" {
+		if _, err := ParseCronSchedule(cron); err != nil {
+			return err
+		}
+	}
+
+	switch overlap {
+	case "", OverlapKill, OverlapQueue, OverlapSkip:
+		return nil
+	}
+
+	return ErrorUnknownOverlapPolicy
+}
+
+func (s *ScheduledServiceConfiguration) Accept(src interface{}) bool {
+	switch src.(type) {
+	case *ScheduledServiceConfiguration:
+		return true
+	case map[string]interface{}:
+		config := src.(map[string]interface{})
+		if val, ok := config["type"]; ok {
+			if val.(string) == TypeScheduledService {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *ScheduledServiceConfiguration) Create(newConfig map[string]interface{}, project *ProjectConfiguration) ServiceInterface {
+	scheduled := &ScheduledServiceConfiguration{}
+	scheduled.Update(newConfig)
+	scheduled.Project = project
+
+	return scheduled
+}
+
+// GetDetails returns the runtime details of the service for the API, which
+// includes when the job runs next
+func (s *ScheduledServiceConfiguration) GetDetails() map[string]interface{} {
+	details := s.RunnableServiceConfiguration.GetDetails()
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.Scheduler != nil && !s.NextRun.IsZero() {
+		details["next_run"] = s.NextRun
+	}
+
+	details["queued"] = s.Queued
+	return details
+}
+
+// GetOverlap returns what happens to a run that comes due while the last one
+// is still going
+func (s *ScheduledServiceConfiguration) GetOverlap() string {
+	if s.Overlap == "" {
+		return OverlapSkip
+	}
+
+	return s.Overlap
+}
+
+// GetState returns the state of the current run, or that the job is
+// scheduled if there's no run going
+func (s *ScheduledServiceConfiguration) GetState() string {
+	if s.Scheduler != nil && !s.isRunning() {
+		return ServiceScheduled
+	}
+
+	return s.getState()
+}
+
+// getNextRun returns when the job next runs after the given time, or the zero
+// time if it never does
+func (s *ScheduledServiceConfiguration) getNextRun(after time.Time) time.Time {
+	if s.Interval > 0 {
+		return after.Add(time.Duration(s.Interval) * time.Second)
+	}
+
+	schedule, err := ParseCronSchedule(s.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return schedule.Next(after)
+}
+
+// Restart stops the schedule, along with the current run, and starts it up
+// again
+func (s *ScheduledServiceConfiguration) Restart() bool {
+	return s.restart(s.Stop, s.Start)
+}
+
+// Scale refuses to scale the job, since every run of it is already a copy of
+// its own
+func (s *ScheduledServiceConfiguration) Scale(replicas int) error {
+	return ErrorServiceNotScalable
+}
+
+// Start starts running the job on its schedule
+func (s *ScheduledServiceConfiguration) Start() bool {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.Scheduler != nil {
+		return false
+	}
+
+	if err := validateSchedule(s.Cron, s.Interval, s.Overlap); err != nil {
+		s.Fail(err)
+		return false
+	}
+
+	s.Scheduler = make(chan struct{})
+	go s.runSchedule(s.Scheduler)
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(s.GetState(), s)
+	})
+
+	return true
+}
+
+// Stop stops the schedule, along with the current run if there is one. The
+// run gets stopped once the lock has been let go of, since that waits out its
+// grace period and the details shouldn't hang on it
+func (s *ScheduledServiceConfiguration) Stop() bool {
+	s.Mutex.Lock()
+	if s.Scheduler == nil && !s.isRunning() {
+		s.Mutex.Unlock()
+		return false
+	}
+
+	if s.Scheduler != nil {
+		close(s.Scheduler)
+		s.Scheduler = nil
+		s.Queued = false
+		logServiceMessage(s, "Schedule was stopped")
+	}
+
+	s.cancelRestart()
+	s.Mutex.Unlock()
+
+	if s.isRunning() {
+		s.stopProcess()
+	}
+
+	s.setState(ServiceStopped)
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(s.GetState(), s)
+	})
+
+	return true
+}
+
+// runSchedule waits for each run to come due and kicks it off, until the
+// schedule is stopped
+func (s *ScheduledServiceConfiguration) runSchedule(done chan struct{}) {
+	for {
+		s.Mutex.Lock()
+		s.NextRun = s.getNextRun(time.Now())
+		next := s.NextRun
+		s.Mutex.Unlock()
+
+		if next.IsZero() {
+			logServiceMessage(s, "The cron expression never comes due, so nothing will run")
+			return
+		}
+
+		Debug.Println("Next run of", s.Name, "is at", next)
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.runDue(done)
+	}
+}
+
+// runDue starts a run that has come due, unless the overlap policy says
+// otherwise
+func (s *ScheduledServiceConfiguration) runDue(done chan struct{}) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	// Make sure we weren't stopped while waiting on the lock
+	select {
+	case <-done:
+		return
+	default:
+	}
+
+	if s.isRunning() {
+		switch s.GetOverlap() {
+		case OverlapKill:
+			logServiceMessage(s, "The previous run is still going, stopping it")
+
+			// Stopping waits out the run's grace period, so let go of the
+			// lock in the meantime
+			s.Mutex.Unlock()
+			s.stopProcess()
+			s.Mutex.Lock()
+
+			// Someone may have stopped the schedule, or started a run of
+			// their own, while we weren't holding the lock
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if s.isRunning() {
+				return
+			}
+		case OverlapQueue:
+			if !s.Queued {
+				logServiceMessage(s, "The previous run is still going, queueing this one up behind it")
+				s.Queued = true
+			}
+			return
+		default:
+			logServiceMessage(s, "The previous run is still going, skipping this one")
+			return
+		}
+	}
+
+	s.startRun(done)
+}
+
+// startRun starts a run, which the caller must hold the lock for. The run
+// is marked off from the last one in the log
+func (s *ScheduledServiceConfiguration) startRun(done chan struct{}) {
+	logServiceMessage(s, fmt.Sprintf("---------- Run started at %s ----------",
+		time.Now().Format(ScheduleTimeFormat)))
+
+	if s.freshStart(TriggerSchedule, "") {
+		go s.awaitRun(s.getProcess(), done)
+	}
+}
+
+// awaitRun waits for a run to finish, then starts the queued up run if there
+// is one
+func (s *ScheduledServiceConfiguration) awaitRun(process *ServiceProcess, done chan struct{}) {
+	<-process.Exited
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	select {
+	case <-done:
+		return
+	default:
+	}
+
+	// Someone else, like the restart policy, may already have a new run going
+	if s.isRunning() {
+		return
+	}
+
+	if s.Queued {
+		s.Queued = false
+		s.startRun(done)
+		return
+	}
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(s.GetState(), s)
+	})
+}
+
+// Update updates the service configuration
+func (s *ScheduledServiceConfiguration) Update(newConfig map[string]interface{}) error {
+	if s.Scheduler != nil {
+		return ErrorCannotModifyService
+	}
+
+	data, err := json.Marshal(newConfig)
+	if err != nil {
+		Error.Println("Error while loading new scheduled service:", err)
+		return err
+	}
+
+	var shimService *ScheduledServiceConfiguration
+	err = json.Unmarshal(data, &shimService)
+	if err != nil {
+		Error.Print("Error while unmarshaling new scheduled service", err)
+		return err
+	}
+
+	// Switching between a cron expression and an interval means clearing
+	// out the other one, so they're taken as given
+	cron, interval := s.Cron, s.Interval
+	if _, ok := newConfig["cron"]; ok {
+		cron = shimService.Cron
+	}
+
+	if _, ok := newConfig["interval"]; ok {
+		interval = shimService.Interval
+	}
+
+	overlap := s.Overlap
+	if shimService.Overlap != "" {
+		overlap = shimService.Overlap
+	}
+
+	if err = validateSchedule(cron, interval, overlap); err != nil {
+		return err
+	}
+
+	if shimService.Detached {
+		return ErrorDetachedNotSupported
+	}
+
+	if err = s.RunnableServiceConfiguration.Update(newConfig); err != nil {
+		return err
+	}
+
+	s.Cron = cron
+	s.Interval = interval
+	s.Overlap = overlap
+	s.Replicas = 0
+
+	s.Type = TypeScheduledService
+	return nil
+}
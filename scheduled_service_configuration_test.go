@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduledStopLetsGoOfTheLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The slow pre_stop hook keeps the stop going long enough to check on
+	// the details in the middle of it
+	project := &ProjectConfiguration{Name: "scheduled"}
+	service := ServiceInterfaces[TypeScheduledService].Create(map[string]interface{}{
+		"type":        TypeScheduledService,
+		"name":        "scheduled",
+		"working_dir": dir,
+		"interval":    1,
+		"pre_stop":    []string{"sleep 1"},
+		"commands":    []string{"sleep 30"},
+	}, project).(*ScheduledServiceConfiguration)
+
+	if !service.Start() {
+		t.Fatal("Expected the schedule to start")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !service.isRunning() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !service.isRunning() {
+		t.Fatal("Expected the first run to start")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		service.Stop()
+		close(stopped)
+	}()
+
+	for !loggedLine(service, "Schedule was stopped") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	details := make(chan map[string]interface{}, 1)
+	go func() {
+		details <- service.GetDetails()
+	}()
+
+	select {
+	case <-details:
+	case <-time.After(500 * time.Millisecond):
+		t.Error("Expected the details while the run was being stopped")
+	}
+
+	<-stopped
+}
+
+// loggedLine tests whether the line shows up anywhere in the service's log
+func loggedLine(service ServiceInterface, line string) bool {
+	logs := service.GetLogs()
+	logs.Mutex.Lock()
+	defer logs.Mutex.Unlock()
+
+	for entry := logs.Root; entry != nil; entry = entry.Next {
+		if strings.Contains(entry.Line, line) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -25,8 +25,8 @@ func buildMux() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/v1/logs", HandleLogs)
 	mux.HandleFunc("/api/v1/projects", HandleProjects)
-	mux.HandleFunc("/api/v1/project/", HandleProjectCrud)
-	mux.HandleFunc("/api/v1/service/", HandleServiceCrud)
+	mux.HandleFunc(ProjectAPIPrefix, HandleProjectCrud)
+	mux.HandleFunc(ServiceAPIPrefix, HandleServiceCrud)
 	mux.HandleFunc("/ws", HandleWebsocket)
 	mux.HandleFunc("/login", HandleLogin)
 
@@ -154,18 +154,27 @@ func HandleLogs(rw http.ResponseWriter, req *http.Request) {
 
 // HandleProjects returns the set of available projects
 func HandleProjects(rw http.ResponseWriter, req *http.Request) {
-	json.NewEncoder(rw).Encode(Config.Projects)
+	json.NewEncoder(rw).Encode(buildProjectsPayload())
 }
 
 // HandleProjectCrud handles the CRUD operations for projects
 func HandleProjectCrud(rw http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
+	if projectID, action := parseProjectPath(req.URL.Path); action != "" {
+		handleProjectAction(rw, req, projectID, action)
+		return
+	}
+
 	// Get the project ID off of the path
 	projectName := path.Base(req.RequestURI)
 
 	if req.Method == http.MethodGet {
-		json.NewEncoder(rw).Encode(Config.Projects[projectName])
+		if project, ok := Config.Projects[projectName]; ok {
+			json.NewEncoder(rw).Encode(buildProjectPayload(project))
+		} else {
+			json.NewEncoder(rw).Encode(nil)
+		}
 	} else {
 		var config *ProjectConfiguration
 		data, _ := ioutil.ReadAll(req.Body)
@@ -178,7 +187,7 @@ func HandleProjectCrud(rw http.ResponseWriter, req *http.Request) {
 		} else {
 			statusReport := &ErrorReport{}
 			if !handleProjectUpdate(statusReport, config) {
-				handleProjectCreation(config)
+				handleProjectCreation(statusReport, config)
 			}
 
 			// Are we returning something that's a full error?
@@ -225,17 +234,22 @@ func HandleRootDev(rw http.ResponseWriter, req *http.Request) {
 func HandleServiceCrud(rw http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	// Get the project ID off of the path
-	serviceID := path.Base(req.RequestURI)
+	// Get the service ID (and any action) off of the path
+	serviceID, action := parseServicePath(req.URL.Path)
 	service, err := findService(serviceID)
 
-	if err != nil {
+	if service == nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	if req.Method == http.MethodGet {
-		json.NewEncoder(rw).Encode(service)
+	if action != "" {
+		handleServiceAction(rw, req, service, action)
+	} else if req.Method == http.MethodGet {
+		// Running services can still be looked at, they just can't be changed
+		json.NewEncoder(rw).Encode(buildServicePayload(service))
+	} else if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
 	} else {
 		// Has to be generic, since we don't know what kind of service it is
 		var config map[string]interface{}
@@ -247,7 +261,11 @@ func HandleServiceCrud(rw http.ResponseWriter, req *http.Request) {
 		if err != nil {
 			handleBadRequest(rw, req, err, string(data))
 		} else {
-			err := service.Update(config)
+			err := service.GetProject().validateServiceUpdate(service, config)
+			if err == nil {
+				err = service.Update(config)
+			}
+
 			if err != nil {
 				Error.Println("Error while updating service")
 				json.NewEncoder(rw).Encode(&ErrorReport{
@@ -267,13 +285,74 @@ func HandleServiceCrud(rw http.ResponseWriter, req *http.Request) {
 // SUPPORT FUNCTIONS
 //////////////////////////////////////
 
+// buildServicePayload builds the API representation of a service, which is
+// its configuration plus its current state and any runtime details it has
+func buildServicePayload(service ServiceInterface) map[string]interface{} {
+	payload := make(map[string]interface{})
+
+	data, err := json.Marshal(service)
+	if err != nil {
+		Error.Println("Error while marshaling service:", err)
+	} else if err = json.Unmarshal(data, &payload); err != nil {
+		Error.Println("Error while building service payload:", err)
+	}
+
+	maskPayloadEnv(payload)
+	payload["state"] = service.GetState()
+
+	if detailed, ok := service.(DetailedService); ok {
+		for key, value := range detailed.GetDetails() {
+			payload[key] = value
+		}
+	}
+
+	return payload
+}
+
+// buildProjectPayload builds the API representation of a project, which is
+// its configuration with the env of the project and its services masked
+func buildProjectPayload(project *ProjectConfiguration) map[string]interface{} {
+	payload := make(map[string]interface{})
+
+	data, err := json.Marshal(project)
+	if err != nil {
+		Error.Println("Error while marshaling project:", err)
+	} else if err = json.Unmarshal(data, &payload); err != nil {
+		Error.Println("Error while building project payload:", err)
+	}
+
+	maskPayloadEnv(payload)
+	if services, ok := payload["services"].([]interface{}); ok {
+		for _, service := range services {
+			if servicePayload, ok := service.(map[string]interface{}); ok {
+				maskPayloadEnv(servicePayload)
+			}
+		}
+	}
+
+	return payload
+}
+
+// buildProjectsPayload builds the API representation of every project
+func buildProjectsPayload() map[string]interface{} {
+	payload := make(map[string]interface{}, len(Config.Projects))
+	for key, project := range Config.Projects {
+		payload[key] = buildProjectPayload(project)
+	}
+
+	return payload
+}
+
 func findService(serviceID string) (ServiceInterface, error) {
 	for _, proj := range Config.Projects {
 		for _, serviceBlob := range proj.Services {
 			service := serviceBlob.(ServiceInterface)
+			if replica := matchReplica(serviceBlob, serviceID); replica != nil {
+				service = replica
+			}
 
 			if service.GetID() == serviceID {
-				if service.GetState() == ServiceRunning {
+				if IsActiveState(service.GetState()) {
 					return service, ErrorCannotModifyService
 				}
 
@@ -285,6 +364,24 @@ func findService(serviceID string) (ServiceInterface, error) {
 	return nil, ErrorCannotMatchService
 }
 
+// requestingUser works out who a request is from, going by the same username
+// and token query parameters the websocket is opened with. A request without
+// a valid token isn't on behalf of anyone
+func requestingUser(req *http.Request) string {
+	username := req.URL.Query().Get("username")
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		return ""
+	}
+
+	usr, ok := loggedInUsers.Get(username)
+	if !ok || usr.(*User).ParseTokenString(token) == nil {
+		return ""
+	}
+
+	return username
+}
+
 // handleBadRequest is a generic error reporter for when something in the request
 // fails to parse appropriately
 func handleBadRequest(rw http.ResponseWriter, req *http.Request, err error, data string) {
@@ -300,13 +397,22 @@ func handleBadRequest(rw http.ResponseWriter, req *http.Request, err error, data
 
 // handleProjectCreation takes in an update and assigns it to a new project
 // entry in the configuration
-func handleProjectCreation(update *ProjectConfiguration) {
+func handleProjectCreation(resp *ErrorReport, update *ProjectConfiguration) {
 	newProject := &ProjectConfiguration{}
-	newProject.Update(update)
-	for _, user := range activeUsers {
-		user.Subscribe(newProject)
+	err := newProject.Update(update)
+
+	// Don't keep half built projects around, tell the caller what went wrong
+	if err != nil && err != ErrorCannotMatchService {
+		resp.Level = ErrorLevelError
+		resp.Message = err.Error()
+		resp.Type = ErrorTypeUpdateFailure
+		return
 	}
 
+	ForEachActiveUser(func(user *User) {
+		user.Subscribe(newProject)
+	})
+
 	Debug.Println("Created new project:", newProject)
 	Config.Projects[newProject.ID] = newProject
 	Config.Save()
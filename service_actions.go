@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ServiceAPIPrefix the route prefix that all of the service endpoints
+	// live under
+	ServiceAPIPrefix = "/api/v1/service/"
+)
+
+// ServiceActionHandler handles a single REST action against a service
+type ServiceActionHandler func(rw http.ResponseWriter, req *http.Request, service ServiceInterface)
+
+// ActionErrorStatuses the HTTP status each of the errors a service action
+// can fail with gets reported as. Anything not in here is a server error
+var ActionErrorStatuses = map[error]int{
+	// Asking for something that makes no sense for the service
+	ErrorCannotKillLeader:      http.StatusBadRequest,
+	ErrorInvalidPorts:          http.StatusBadRequest,
+	ErrorInvalidPtySize:        http.StatusBadRequest,
+	ErrorInvalidReplicas:       http.StatusBadRequest,
+	ErrorProcessNotInService:   http.StatusBadRequest,
+	ErrorServiceNoHistory:      http.StatusBadRequest,
+	ErrorServiceNotInspectable: http.StatusBadRequest,
+	ErrorServiceNotInteractive: http.StatusBadRequest,
+	ErrorServiceNotScalable:    http.StatusBadRequest,
+	ErrorServiceNotSignalable:  http.StatusBadRequest,
+	ErrorServiceNotTask:        http.StatusBadRequest,
+	ErrorServiceNotTerminal:    http.StatusBadRequest,
+	ErrorSignalNotAllowed:      http.StatusBadRequest,
+	ErrorUnknownSignal:         http.StatusBadRequest,
+
+	// Asking at the wrong time, given what the service is up to
+	ErrorCannotModifyReplica:   http.StatusConflict,
+	ErrorCannotModifyService:   http.StatusConflict,
+	ErrorServiceAdopted:        http.StatusConflict,
+	ErrorServiceAlreadyRunning: http.StatusConflict,
+	ErrorServiceDetached:       http.StatusConflict,
+	ErrorServiceNotRunning:     http.StatusConflict,
+}
+
+// ServiceKillRequest the payload for killing one of a service's processes
+type ServiceKillRequest struct {
+	Force bool `json:"force,omitempty"`
+	PID   int  `json:"pid"`
+}
+
+// ServiceResizeRequest the payload for resizing a service's terminal
+type ServiceResizeRequest struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// ServiceScaleRequest the payload for scaling a service
+type ServiceScaleRequest struct {
+	Replicas int `json:"replicas"`
+}
+
+// ServiceSignalRequest the payload for sending a signal to a service
+type ServiceSignalRequest struct {
+	LeaderOnly bool   `json:"leader_only,omitempty"`
+	Signal     string `json:"signal"`
+}
+
+// ServiceInputRequest the payload for sending input to a service
+type ServiceInputRequest struct {
+	Input string `json:"input"`
+	Raw   bool   `json:"raw,omitempty"`
+}
+
+var (
+	// ServiceActions the set of actions that can be performed against a
+	// service via /api/v1/service/<id>/<action>
+	ServiceActions = map[string]ServiceActionHandler{
+		CommandHistory:   HandleServiceHistory,
+		CommandInput:     HandleServiceInput,
+		CommandKill:      HandleServiceKill,
+		CommandProcesses: HandleServiceProcesses,
+		CommandResize:    HandleServiceResize,
+		CommandRestart:   HandleServiceRestart,
+		CommandRun:       HandleServiceRun,
+		CommandScale:     HandleServiceScale,
+		CommandSignal:    HandleServiceSignal,
+	}
+)
+
+// HandleServiceHistory returns the run history of a service, oldest first
+func HandleServiceHistory(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodGet {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	historical, ok := service.(HistoricalService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNoHistory)
+		return
+	}
+
+	json.NewEncoder(rw).Encode(historical.GetHistory().GetRuns())
+}
+
+// HandleServiceInput sends input to the stdin of a running service
+func HandleServiceInput(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	interactive, ok := service.(InteractiveService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNotInteractive)
+		return
+	}
+
+	var input ServiceInputRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		handleBadRequest(rw, req, err, "")
+		return
+	}
+
+	data := input.Input
+	if !input.Raw {
+		data = data + "\n"
+	}
+
+	writeActionResult(rw, interactive.WriteInput([]byte(data)))
+}
+
+// HandleServiceKill kills a single process of a running service
+func HandleServiceKill(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	inspectable, ok := service.(InspectableService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNotInspectable)
+		return
+	}
+
+	var kill ServiceKillRequest
+	if err := json.NewDecoder(req.Body).Decode(&kill); err != nil {
+		handleBadRequest(rw, req, err, "")
+		return
+	}
+
+	writeActionResult(rw, inspectable.KillProcess(kill.PID, kill.Force))
+}
+
+// HandleServiceProcesses returns the tree of processes a running service has
+// going, starting from the leader of its process group
+func HandleServiceProcesses(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodGet {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	inspectable, ok := service.(InspectableService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNotInspectable)
+		return
+	}
+
+	processes, err := inspectable.GetProcesses()
+	if err != nil {
+		writeActionResult(rw, err)
+		return
+	}
+
+	json.NewEncoder(rw).Encode(processes)
+}
+
+// HandleServiceResize changes the window size of a service's terminal
+func HandleServiceResize(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	resizable, ok := service.(ResizableService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNotTerminal)
+		return
+	}
+
+	var size ServiceResizeRequest
+	if err := json.NewDecoder(req.Body).Decode(&size); err != nil {
+		handleBadRequest(rw, req, err, "")
+		return
+	}
+
+	writeActionResult(rw, resizable.Resize(size.Cols, size.Rows))
+}
+
+// HandleServiceRestart stops a service and starts it again once its old
+// process is gone
+func HandleServiceRestart(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !restartService(service, requestingUser(req)) {
+		writeActionResult(rw, ErrorServiceNotRestarted)
+		return
+	}
+
+	writeActionResult(rw, nil)
+}
+
+// HandleServiceRun runs a task service again
+func HandleServiceRun(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, ok := service.(TaskService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNotTask)
+		return
+	}
+
+	requestStart(service, requestingUser(req))
+	writeActionResult(rw, task.Run())
+}
+
+// HandleServiceScale changes how many replicas of a service get run, which
+// takes effect straight away if the service is running
+func HandleServiceScale(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	scalable, ok := service.(ScalableService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNotScalable)
+		return
+	}
+
+	var scale ServiceScaleRequest
+	if err := json.NewDecoder(req.Body).Decode(&scale); err != nil {
+		handleBadRequest(rw, req, err, "")
+		return
+	}
+
+	err := scalable.Scale(scale.Replicas)
+	if err == nil {
+		broadcastProjectUpdate(service.GetProject())
+	}
+
+	writeActionResult(rw, err)
+}
+
+// HandleServiceSignal sends a signal to the process group of a running
+// service, or just to the leader of it
+func HandleServiceSignal(rw http.ResponseWriter, req *http.Request, service ServiceInterface) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	signalable, ok := service.(SignalableService)
+	if !ok {
+		writeActionResult(rw, ErrorServiceNotSignalable)
+		return
+	}
+
+	var signal ServiceSignalRequest
+	if err := json.NewDecoder(req.Body).Decode(&signal); err != nil {
+		handleBadRequest(rw, req, err, "")
+		return
+	}
+
+	writeActionResult(rw, signalable.Signal(signal.Signal, signal.LeaderOnly))
+}
+
+// handleServiceAction dispatches the named action against the service
+func handleServiceAction(rw http.ResponseWriter, req *http.Request, service ServiceInterface, action string) {
+	handler, ok := ServiceActions[action]
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	handler(rw, req, service)
+}
+
+// parseServicePath splits a service route into the service ID and the
+// (optional) action being performed against it
+func parseServicePath(path string) (string, string) {
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(path, ServiceAPIPrefix), "/"), "/", 2)
+
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return parts[0], ""
+}
+
+// actionErrorStatus returns the HTTP status the error from a service action
+// gets reported as
+func actionErrorStatus(err error) int {
+	if status, ok := ActionErrorStatuses[err]; ok {
+		return status
+	}
+
+	// Clashing ports name the services involved, so they don't compare equal
+	if strings.HasPrefix(err.Error(), ErrorDuplicatePorts.Error()) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+// writeActionResult writes back the outcome of a service action
+func writeActionResult(rw http.ResponseWriter, err error) {
+	if err != nil {
+		rw.WriteHeader(actionErrorStatus(err))
+		json.NewEncoder(rw).Encode(&ErrorReport{
+			Level:   ErrorLevelError,
+			Message: err.Error(),
+			Type:    ErrorTypeActionFailure,
+		})
+		return
+	}
+
+	json.NewEncoder(rw).Encode(&ErrorReport{})
+}
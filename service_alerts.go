@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// AlertRepeatInterval how long an alert rule stays quiet after it fires,
+	// so that a service spewing the same error over and over doesn't bury
+	// everyone in alerts
+	AlertRepeatInterval = 30 * time.Second
+)
+
+// AlertRule a pattern that means trouble when it shows up in the service's
+// output, like a panic or a fatal error, even though the service is still
+// up. A match moves the service into the degraded state, and can restart it
+type AlertRule struct {
+	Name    string `json:"name,omitempty"`
+	Pattern string `json:"pattern"`
+	Restart bool   `json:"restart,omitempty"`
+}
+
+// AlertCheck tracks the alert rules against a single service process
+type AlertCheck struct {
+	Fired      []time.Time
+	Mutex      *sync.Mutex
+	Patterns   []*regexp.Regexp
+	Restarting bool
+	Rules      []*AlertRule
+}
+
+// NewAlertCheck creates a new check for the given rules
+func NewAlertCheck(rules []*AlertRule) (*AlertCheck, error) {
+	check := &AlertCheck{
+		Fired:    make([]time.Time, len(rules)),
+		Mutex:    &sync.Mutex{},
+		Patterns: make([]*regexp.Regexp, len(rules)),
+		Rules:    rules}
+
+	for i, rule := range rules {
+		if rule == nil || rule.Pattern == "" {
+			return nil, ErrorInvalidAlertRule
+		}
+
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		check.Patterns[i] = pattern
+	}
+
+	return check, nil
+}
+
+// GetName returns what the rule is called in alerts, which is its pattern
+// unless it was given a name
+func (r *AlertRule) GetName() string {
+	if r.Name == "" {
+		return r.Pattern
+	}
+
+	return r.Name
+}
+
+// Match returns the rules the line sets off, leaving out any that have
+// fired too recently to fire again
+func (c *AlertCheck) Match(line string) []*AlertRule {
+	var matched []*AlertRule
+
+	for i, pattern := range c.Patterns {
+		if !pattern.MatchString(line) {
+			continue
+		}
+
+		c.Mutex.Lock()
+		if time.Since(c.Fired[i]) >= AlertRepeatInterval {
+			c.Fired[i] = time.Now()
+			matched = append(matched, c.Rules[i])
+		}
+		c.Mutex.Unlock()
+	}
+
+	return matched
+}
+
+// claimRestart returns true for the first alert to ask for a restart of the
+// process, and false for every one after it, so that several rules going off
+// at once only ever restart the service the once
+func (c *AlertCheck) claimRestart() bool {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.Restarting {
+		return false
+	}
+
+	c.Restarting = true
+	return true
+}
+
+// checkAlerts raises an alert for every rule the line of output sets off
+func (s *ServiceProcess) checkAlerts(line string) {
+	if s.Alerts == nil {
+		return
+	}
+
+	for _, rule := range s.Alerts.Match(line) {
+		s.raiseAlert(rule, line)
+	}
+}
+
+// raiseAlert flags the service as degraded and lets everyone know which rule
+// went off and on what. Restarting has to happen elsewhere, since stopping
+// the process waits on the very listener that spotted the line
+func (s *ServiceProcess) raiseAlert(rule *AlertRule, line string) {
+	config := s.Configuration
+
+	// The process may not be the service's current one just yet if it set
+	// off the alert straight away, so it goes on its own flags instead. A
+	// process on its way out doesn't get a say
+	serviceStateMutex.Lock()
+	current := s.Running && !s.Stopping
+	degrade := current && IsActiveState(config.State) && config.State != ServiceDegraded
+	if degrade {
+		config.State = ServiceDegraded
+	}
+	serviceStateMutex.Unlock()
+
+	if !current {
+		return
+	}
+
+	logServiceMessage(config, fmt.Sprintf("Alert %q was raised by: %s", rule.GetName(), line))
+
+	if degrade {
+		ForEachActiveUser(func(user *User) {
+			user.WriteStatusMessage(ServiceDegraded, config)
+		})
+	}
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteAlertMessage(rule.GetName(), line, config)
+	})
+
+	if rule.Restart && s.Alerts.claimRestart() {
+		go config.restartForAlert(s)
+	}
+}
+
+// restartForAlert restarts the service once an alert rule has asked for it,
+// as long as the process that raised the alert is still the one running
+func (s *RunnableServiceConfiguration) restartForAlert(process *ServiceProcess) {
+	serviceStateMutex.RLock()
+	current := s.Running && s.Process == process && !process.Stopping
+	serviceStateMutex.RUnlock()
+
+	if !current {
+		return
+	}
+
+	logServiceMessage(s, "Restarting because of the alert")
+	s.stopProcess()
+	s.freshStart(TriggerAlert, "")
+}
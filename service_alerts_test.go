@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAlertCheck(t *testing.T) {
+	invalid := [][]*AlertRule{
+		{nil},
+		{{Name: "empty"}},
+		{{Pattern: "("}},
+		{{Pattern: "ok"}, {Pattern: "[unclosed"}},
+	}
+
+	for _, rules := range invalid {
+		if _, err := NewAlertCheck(rules); err == nil {
+			t.Errorf("Expected the rules %+v to be rejected", rules)
+		}
+	}
+
+	if _, err := NewAlertCheck([]*AlertRule{{Pattern: "FATAL"}, {Name: "oom", Pattern: `(?i)out of memory`}}); err != nil {
+		t.Errorf("Expected the rules to be valid, got %s", err)
+	}
+}
+
+func TestAlertCheckMatch(t *testing.T) {
+	fatal := &AlertRule{Pattern: "FATAL"}
+	panics := &AlertRule{Name: "panic", Pattern: `^panic: `}
+
+	check, err := NewAlertCheck([]*AlertRule{fatal, panics})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched := check.Match("all good"); len(matched) != 0 {
+		t.Errorf("Expected nothing to match, got %v", matched)
+	}
+
+	matched := check.Match("panic: FATAL error")
+	if len(matched) != 2 || matched[0] != fatal || matched[1] != panics {
+		t.Errorf("Expected both rules to match, got %v", matched)
+	}
+
+	// Rules stay quiet for a while after they fire
+	if matched = check.Match("FATAL again"); len(matched) != 0 {
+		t.Errorf("Expected the rule not to fire again straight away, got %v", matched)
+	}
+
+	check.Fired[0] = time.Now().Add(-AlertRepeatInterval)
+	if matched = check.Match("FATAL again"); len(matched) != 1 || matched[0] != fatal {
+		t.Errorf("Expected the rule to fire again once it's been quiet long enough, got %v", matched)
+	}
+
+	if fatal.GetName() != "FATAL" || panics.GetName() != "panic" {
+		t.Error("Expected rules to be named after their pattern unless given a name")
+	}
+}
+
+func TestAlertRulesRestartOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Only the first run prints the line, so a second restart could only
+	// come from both rules going off on it. The slow pre_stop hook holds the
+	// process open long enough for both of them to try
+	marker := filepath.Join(dir, "fired")
+	project := &ProjectConfiguration{Name: "alerts"}
+	service := ServiceInterfaces[TypeRunnableService].Create(map[string]interface{}{
+		"type":        TypeRunnableService,
+		"name":        "alerts",
+		"working_dir": dir,
+		"alerts": []map[string]interface{}{
+			{"name": "fatal", "pattern": "FATAL", "restart": true},
+			{"name": "broken", "pattern": "broken", "restart": true},
+		},
+		"pre_stop": []string{"sleep 0.3"},
+		"commands": []string{
+			"[ -f " + marker + " ] || { touch " + marker + "; echo 'FATAL: broken'; }",
+			"sleep 30",
+		},
+	}, project).(*RunnableServiceConfiguration)
+
+	if !service.Start() {
+		t.Fatal("Expected the service to start")
+	}
+	first := service.getProcess()
+
+	select {
+	case <-first.Exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the alerts to restart the service")
+	}
+
+	// Both rules have had their say by the time the first process is gone,
+	// so whatever restarts they asked for are already underway
+	deadline := time.Now().Add(5 * time.Second)
+	second := service.runningProcess()
+	for (second == nil || second == first) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		second = service.runningProcess()
+	}
+	service.Stop()
+
+	restarts := 0
+	for entry := service.GetLogs().Root; entry != nil; entry = entry.Next {
+		if strings.Contains(entry.Line, "Restarting because of the alert") {
+			restarts++
+		}
+	}
+
+	if restarts != 1 {
+		t.Errorf("Expected the service to be restarted once, it was restarted %d times", restarts)
+	}
+
+	if second == nil || second == first || second.Run.Trigger != TriggerAlert {
+		t.Errorf("Expected the running process to be the one started by the alert")
+	}
+}
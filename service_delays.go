@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// beginOperation cancels whatever project start or stop is still under way
+// and returns the context for a new one
+func (p *ProjectConfiguration) beginOperation() context.Context {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	if p.Cancel != nil {
+		p.Cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Cancel = cancel
+
+	return ctx
+}
+
+// getServiceDelays returns how long to pause before and after starting or
+// stopping the given service
+func getServiceDelays(service ServiceInterface) (time.Duration, time.Duration) {
+	if delayed, ok := service.(DelayedService); ok {
+		return delayed.GetDelayBefore(), delayed.GetDelayAfter()
+	}
+
+	return 0, 0
+}
+
+// pauseForService waits out one of a service's delays, unless the project
+// operation gets cancelled first, in which case it returns false. Services
+// waiting to be started are flagged as such
+func pauseForService(ctx context.Context, service ServiceInterface, delay time.Duration, reason string, waiting bool) bool {
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+
+	delayed, _ := service.(DelayedService)
+	if waiting && delayed != nil {
+		delayed.SetWaiting(true)
+	}
+
+	logServiceMessage(service, fmt.Sprintf("Waiting %s %s", delay, reason))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		if waiting && delayed != nil {
+			delayed.SetWaiting(false)
+		}
+
+		logServiceMessage(service, "Stopped waiting, the project operation was cancelled")
+		return false
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DependencyPollInterval how often to check on a dependency while waiting
+	// for it to become ready
+	DependencyPollInterval = 250 * time.Millisecond
+
+	// DependencyTimeout how long to wait on a dependency before giving up
+	// on it entirely
+	DependencyTimeout = 5 * time.Minute
+)
+
+// dependencyNode is the minimal view of a service needed to order it. It lets
+// us sort both live services and raw (unsaved) service maps the same way
+type dependencyNode struct {
+	DependsOn []string
+	ID        string
+	Name      string
+}
+
+// newDependencyNode builds a dependency node from either a service or a raw
+// service configuration map
+func newDependencyNode(src interface{}) dependencyNode {
+	node := dependencyNode{}
+
+	switch src.(type) {
+	case ServiceInterface:
+		service := src.(ServiceInterface)
+		node.DependsOn = service.GetDependencies()
+		node.ID = service.GetID()
+		node.Name = service.GetName()
+	case map[string]interface{}:
+		config := src.(map[string]interface{})
+		if id, ok := config["id"].(string); ok {
+			node.ID = id
+		}
+
+		if name, ok := config["name"].(string); ok {
+			node.Name = name
+		}
+
+		if deps, ok := config["depends_on"].([]interface{}); ok {
+			for _, dep := range deps {
+				if depName, ok := dep.(string); ok {
+					node.DependsOn = append(node.DependsOn, depName)
+				}
+			}
+		}
+	}
+
+	return node
+}
+
+// label returns the most human friendly identifier for the node
+func (d dependencyNode) label() string {
+	if d.Name != "" {
+		return d.Name
+	}
+
+	return d.ID
+}
+
+// matches tests whether the given reference (either a name or an ID) points
+// at this node
+func (d dependencyNode) matches(ref string) bool {
+	return ref != "" && (ref == d.ID || ref == d.Name)
+}
+
+// orderDependencyNodes performs a stable topological sort over the given nodes
+// and returns the node indices in start order. Nodes without any ordering
+// constraints retain their original relative order
+func orderDependencyNodes(nodes []dependencyNode) ([]int, error) {
+	// edges[i] holds the indices of the nodes that depend on node i
+	edges := make([][]int, len(nodes))
+	pending := make([]int, len(nodes))
+
+	for i, node := range nodes {
+		for _, ref := range node.DependsOn {
+			matched := false
+
+			for j, candidate := range nodes {
+				if candidate.matches(ref) {
+					if i == j {
+						return nil, fmt.Errorf("The service %q cannot depend on itself", node.label())
+					}
+
+					matched = true
+					edges[j] = append(edges[j], i)
+					pending[i]++
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("The service %q depends on an unknown service %q", node.label(), ref)
+			}
+		}
+	}
+
+	order := make([]int, 0, len(nodes))
+	visited := make([]bool, len(nodes))
+
+	for len(order) < len(nodes) {
+		progressed := false
+
+		// Always pick the first ready node so the ordering stays stable
+		for i := range nodes {
+			if !visited[i] && pending[i] == 0 {
+				visited[i] = true
+				progressed = true
+				order = append(order, i)
+
+				for _, dependent := range edges[i] {
+					pending[dependent]--
+				}
+				break
+			}
+		}
+
+		if !progressed {
+			var cycle []string
+			for i, node := range nodes {
+				if !visited[i] {
+					cycle = append(cycle, node.label())
+				}
+			}
+
+			return nil, fmt.Errorf("The services contain a dependency cycle involving: %s", strings.Join(cycle, ", "))
+		}
+	}
+
+	return order, nil
+}
+
+// orderServices returns the given services sorted so that every service comes
+// after all of the services it depends on
+func orderServices(services []interface{}) ([]ServiceInterface, error) {
+	nodes := make([]dependencyNode, len(services))
+	for i, service := range services {
+		nodes[i] = newDependencyNode(service)
+	}
+
+	order, err := orderDependencyNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]ServiceInterface, len(order))
+	for i, index := range order {
+		ordered[i] = services[index].(ServiceInterface)
+	}
+
+	return ordered, nil
+}
+
+// validateServiceDependencies makes sure the given set of services (or raw
+// service maps) can actually be ordered
+func validateServiceDependencies(services []interface{}) error {
+	nodes := make([]dependencyNode, len(services))
+	for i, service := range services {
+		nodes[i] = newDependencyNode(service)
+	}
+
+	_, err := orderDependencyNodes(nodes)
+	return err
+}
+
+// mergeDependencyUpdate returns the raw service map the service would order
+// as once updated with the given config. The update only carries what's
+// changing, so anything it leaves out comes from the service
+func mergeDependencyUpdate(service ServiceInterface, config map[string]interface{}) map[string]interface{} {
+	dependsOn := []interface{}{}
+	for _, dep := range service.GetDependencies() {
+		dependsOn = append(dependsOn, dep)
+	}
+
+	merged := map[string]interface{}{
+		"depends_on": dependsOn,
+		"id":         service.GetID(),
+		"name":       service.GetName()}
+
+	for _, key := range []string{"id", "name"} {
+		if value, ok := config[key].(string); ok && value != "" {
+			merged[key] = value
+		}
+	}
+
+	if deps, ok := config["depends_on"].([]interface{}); ok {
+		merged["depends_on"] = deps
+	}
+
+	return merged
+}
+
+// findDependency locates the service within the project that the given
+// reference (name or ID) points at
+func (p *ProjectConfiguration) findDependency(ref string) ServiceInterface {
+	for _, s := range p.Services {
+		service := s.(ServiceInterface)
+
+		if newDependencyNode(service).matches(ref) {
+			return service
+		}
+	}
+
+	return nil
+}
+
+// waitForService blocks until the service is either ready or clearly not
+// going to be, or the project operation gets cancelled. It returns nil only
+// if the service is ready. A task is only ready once it has succeeded, and
+// is waited on for as long as it takes, since migrations and the like can
+// take a while
+func waitForService(ctx context.Context, service ServiceInterface) error {
+	deadline := time.Now().Add(DependencyTimeout)
+	_, task := service.(TaskService)
+
+	for {
+		state := service.GetState()
+
+		if task {
+			switch state {
+			case ServiceSucceeded:
+				return nil
+			case ServiceDegraded, ServicePending, ServiceRunning, ServiceWaiting:
+			default:
+				return fmt.Errorf("%q did not succeed (state: %s)", service.GetName(), state)
+			}
+		} else if IsReadyState(state) {
+			return nil
+		} else if state != ServiceStarting {
+			return fmt.Errorf("%q failed to come up (state: %s)", service.GetName(), state)
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("%q did not become ready within %s", service.GetName(), DependencyTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(DependencyPollInterval):
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrderDependencyNodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []dependencyNode
+		order []int
+		err   string
+	}{
+		{"no dependencies keep their order", []dependencyNode{
+			{Name: "a"}, {Name: "b"}, {Name: "c"},
+		}, []int{0, 1, 2}, ""},
+		{"dependency by name", []dependencyNode{
+			{Name: "api", DependsOn: []string{"db"}}, {Name: "db"},
+		}, []int{1, 0}, ""},
+		{"dependency by ID", []dependencyNode{
+			{ID: "1", Name: "api", DependsOn: []string{"2"}}, {ID: "2", Name: "db"},
+		}, []int{1, 0}, ""},
+		{"chain", []dependencyNode{
+			{Name: "web", DependsOn: []string{"api"}},
+			{Name: "api", DependsOn: []string{"db", "queue"}},
+			{Name: "queue"},
+			{Name: "db"},
+		}, []int{2, 3, 1, 0}, ""},
+		{"stable among the ready ones", []dependencyNode{
+			{Name: "a", DependsOn: []string{"c"}}, {Name: "b"}, {Name: "c"}, {Name: "d"},
+		}, []int{1, 2, 0, 3}, ""},
+		{"self dependency", []dependencyNode{
+			{Name: "a", DependsOn: []string{"a"}},
+		}, nil, `"a" cannot depend on itself`},
+		{"unknown dependency", []dependencyNode{
+			{Name: "a", DependsOn: []string{"missing"}},
+		}, nil, `unknown service "missing"`},
+		{"cycle", []dependencyNode{
+			{Name: "ok"},
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"a"}},
+		}, nil, "cycle involving: a, b"},
+	}
+
+	for _, test := range tests {
+		order, err := orderDependencyNodes(test.nodes)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: expected no error, got %s", test.name, err)
+		} else if !reflect.DeepEqual(order, test.order) {
+			t.Errorf("%s: expected order %v, got %v", test.name, test.order, order)
+		}
+	}
+}
+
+func TestValidateServiceDependenciesMaps(t *testing.T) {
+	valid := []interface{}{
+		map[string]interface{}{"id": "1", "name": "api", "depends_on": []interface{}{"db"}},
+		map[string]interface{}{"name": "db"},
+	}
+	if err := validateServiceDependencies(valid); err != nil {
+		t.Errorf("Expected the services to be valid, got %s", err)
+	}
+
+	cyclic := []interface{}{
+		map[string]interface{}{"name": "api", "depends_on": []interface{}{"db"}},
+		map[string]interface{}{"name": "db", "depends_on": []interface{}{"api"}},
+	}
+	if err := validateServiceDependencies(cyclic); err == nil {
+		t.Error("Expected the dependency cycle to be rejected")
+	}
+}
+
+func TestValidateServiceUpdateDependencies(t *testing.T) {
+	project := &ProjectConfiguration{Name: "dependencies"}
+	api := &RunnableServiceConfiguration{ID: "1", Name: "api", DependsOn: []string{"db"}, Project: project}
+	db := &RunnableServiceConfiguration{ID: "2", Name: "db", Project: project}
+	project.Services = []interface{}{api, db}
+
+	tests := []struct {
+		name    string
+		service ServiceInterface
+		config  map[string]interface{}
+		valid   bool
+	}{
+		{"unrelated change", db, map[string]interface{}{"description": "database"}, true},
+		{"no dependencies", db, map[string]interface{}{"depends_on": []interface{}{}}, true},
+		{"cycle", db, map[string]interface{}{"depends_on": []interface{}{"api"}}, false},
+		{"renamed dependency", db, map[string]interface{}{"name": "postgres"}, false},
+		{"unknown dependency", api, map[string]interface{}{"depends_on": []interface{}{"cache"}}, false},
+	}
+
+	for _, test := range tests {
+		err := project.validateServiceUpdate(test.service, test.config)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected the update to be valid, got %s", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: expected the update to be rejected", test.name)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// HistoryDirName the directory under the orchestra home that run
+	// histories are kept in
+	HistoryDirName = "history"
+
+	// HistoryStatusListed the history message holds the full history
+	HistoryStatusListed = "listed"
+
+	// HistoryStatusRecorded the history message holds a run that just ended
+	HistoryStatusRecorded = "recorded"
+
+	// ServiceDefaultRunHistoryLimit how many runs are kept per service
+	ServiceDefaultRunHistoryLimit = 50
+
+	// TriggerAlert the run was started because an alert rule asked for a
+	// restart
+	TriggerAlert = "alert"
+
+	// TriggerFileWatch the run was started because the service's files
+	// changed
+	TriggerFileWatch = "file_watch"
+
+	// TriggerManual the run was started by hand
+	TriggerManual = "manual"
+
+	// TriggerProject the run was started as part of starting its project
+	TriggerProject = "project"
+
+	// TriggerRestart the run was started by the restart policy
+	TriggerRestart = "restart_policy"
+
+	// TriggerSchedule the run was started by the service's schedule
+	TriggerSchedule = "schedule"
+)
+
+// ServiceRun the record of a single run of a service. A run that never got
+// launched has neither an exit code nor a signal, just an error
+type ServiceRun struct {
+	Duration  float64   `json:"duration"`
+	Error     string    `json:"error,omitempty"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+	Limit     string    `json:"limit,omitempty"`
+	Restarts  int       `json:"restarts"`
+	Signal    string    `json:"signal,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	State     string    `json:"state"`
+	StoppedAt time.Time `json:"stopped_at"`
+	Trigger   string    `json:"trigger"`
+	User      string    `json:"user,omitempty"`
+}
+
+// ServiceHistory the runs of a service, newest last, which are persisted
+// under the orchestra home so they survive a restart of orchestra itself
+type ServiceHistory struct {
+	Mutex sync.Mutex    `json:"-"`
+	Path  string        `json:"-"`
+	Runs  []*ServiceRun `json:"runs"`
+}
+
+// NewServiceRun starts the record for a run that is starting right now. The
+// user is whoever asked for the run, if anyone did
+func NewServiceRun(trigger, user string, restarts int) *ServiceRun {
+	return &ServiceRun{
+		Restarts:  restarts,
+		StartedAt: time.Now(),
+		Trigger:   trigger,
+		User:      user}
+}
+
+// requestStart flags the next start of the service as asked for by the given
+// user, for services that keep a history of their runs
+func requestStart(service ServiceInterface, user string) {
+	if historical, ok := service.(HistoricalService); ok {
+		historical.SetTrigger(TriggerManual, user)
+	}
+}
+
+// Finish records how the run ended
+func (r *ServiceRun) Finish(state string, processState *os.ProcessState) {
+	r.State = state
+	r.StoppedAt = time.Now()
+	r.Duration = r.StoppedAt.Sub(r.StartedAt).Seconds()
+
+	if processState == nil {
+		return
+	}
+
+	// Processes that went down to a signal don't have an exit code
+	if status, ok := processState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		r.Signal = signalName(status.Signal())
+	} else if ok {
+		code := status.ExitStatus()
+		r.ExitCode = &code
+	}
+}
+
+// loadServiceHistory reads the persisted history for the given service,
+// starting a fresh one if there isn't any yet
+func loadServiceHistory(serviceID string) *ServiceHistory {
+	history := &ServiceHistory{
+		Path: filepath.Join(getHomeDir(), DefaultHomePath, HistoryDirName, serviceID+".json")}
+
+	data, err := ioutil.ReadFile(history.Path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Error.Println("Could not read the run history:", err)
+		}
+
+		return history
+	}
+
+	if err = json.Unmarshal(data, history); err != nil {
+		Error.Println("Could not parse the run history:", err)
+	}
+
+	return history
+}
+
+// GetRuns returns a copy of the recorded runs
+func (h *ServiceHistory) GetRuns() []*ServiceRun {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	return append([]*ServiceRun{}, h.Runs...)
+}
+
+// Record adds a finished run to the history and persists it
+func (h *ServiceHistory) Record(run *ServiceRun) error {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	h.Runs = append(h.Runs, run)
+	if len(h.Runs) > ServiceDefaultRunHistoryLimit {
+		h.Runs = h.Runs[len(h.Runs)-ServiceDefaultRunHistoryLimit:]
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(h.Path), 0775); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(h.Path, data, 0644)
+}
+
+// recordRun saves a finished run into the service's history and lets
+// everyone watching know about it
+func (s *RunnableServiceConfiguration) recordRun(run *ServiceRun) {
+	if err := s.GetHistory().Record(run); err != nil {
+		Error.Println("Could not save the run history for", s.Name+":", err)
+	}
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteHistoryMessage(HistoryStatusRecorded, []*ServiceRun{run}, s)
+	})
+}
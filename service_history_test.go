@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestServiceHistoryPersists(t *testing.T) {
+	history := loadServiceHistory("history-test")
+	if !strings.HasPrefix(history.Path, os.Getenv(OrchestraHomeEnvVar)) {
+		t.Fatalf("Expected the history to be kept under the test home, got %s", history.Path)
+	}
+
+	for i := 0; i < ServiceDefaultRunHistoryLimit+5; i++ {
+		run := NewServiceRun(TriggerManual, "", i)
+		run.Finish(ServiceStopped, nil)
+		if err := history.Record(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs := loadServiceHistory("history-test").GetRuns()
+	if len(runs) != ServiceDefaultRunHistoryLimit {
+		t.Fatalf("Expected %d runs to be kept, got %d", ServiceDefaultRunHistoryLimit, len(runs))
+	}
+
+	if runs[0].Restarts != 5 || runs[len(runs)-1].Trigger != TriggerManual {
+		t.Errorf("Expected the oldest runs to be dropped, got %+v first", runs[0])
+	}
+}
+
+func TestServiceRunFinish(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "exit 3")
+	cmd.Run()
+
+	run := NewServiceRun(TriggerProject, "", 0)
+	run.Finish(ServiceDead, cmd.ProcessState)
+	if run.ExitCode == nil || *run.ExitCode != 3 || run.Signal != "" {
+		t.Errorf("Expected exit code 3 and no signal, got %v and %q", run.ExitCode, run.Signal)
+	}
+
+	cmd = exec.Command("sh", "-c", "kill -TERM $$")
+	cmd.Run()
+
+	run = NewServiceRun(TriggerProject, "", 0)
+	run.Finish(ServiceDead, cmd.ProcessState)
+	if run.ExitCode != nil || run.Signal != "SIGTERM" {
+		t.Errorf("Expected SIGTERM and no exit code, got %v and %q", run.ExitCode, run.Signal)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// HookPostStart runs once the service is up (and ready, if it has a
+	// readiness probe)
+	HookPostStart = "post_start"
+
+	// HookPostStop runs once the service has been stopped
+	HookPostStop = "post_stop"
+
+	// HookPreStart runs before the service is launched, and stops it from
+	// being launched if it fails
+	HookPreStart = "pre_start"
+
+	// HookPreStop runs before the service is sent its stop signal
+	HookPreStop = "pre_stop"
+
+	// LoggedCommandTimeout how long a hook, rebuild or clone gets to finish
+	// before it's killed, so one that never exits can't hang the service
+	LoggedCommandTimeout = 15 * time.Minute
+)
+
+// runLoggedCommand runs the command, copying its output into the logs of
+// each of the given services as it comes in. The command gets its own
+// process group, which is killed outright if it runs past the timeout
+func runLoggedCommand(cmd *exec.Cmd, timeout time.Duration, services ...ServiceInterface) error {
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	timer := time.AfterFunc(timeout, func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+		writer.Close()
+	}()
+
+	logOutput(reader, services)
+
+	// A command that finished just as the timer went off still counts
+	err := <-done
+	if !timer.Stop() && err != nil {
+		return fmt.Errorf("%s after %s", ErrorCommandTimedOut, timeout)
+	}
+
+	return err
+}
+
+// logOutput copies each line read into the logs of the given services. Lines
+// can be any length, and whatever is left once the reader fails is drained,
+// since the command would block writing to a pipe nobody reads
+func logOutput(reader io.Reader, services []ServiceInterface) {
+	buffered := bufio.NewReader(reader)
+
+	for {
+		line, err := buffered.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" || err == nil {
+			for _, service := range services {
+				service.GetLogs().Append(line)
+				broadcastProcessData(service)
+			}
+		}
+
+		if err != nil {
+			io.Copy(ioutil.Discard, reader)
+			return
+		}
+	}
+}
+
+// runHooks runs each of the hook's commands in order, logging them into the
+// given services, and stops at the first one that fails
+func runHooks(hook string, commands []string, build func(string) (*exec.Cmd, error), services []ServiceInterface) error {
+	for _, command := range commands {
+		for _, service := range services {
+			logServiceMessage(service, "Running "+hook+" hook: "+command)
+		}
+
+		cmd, err := build(command)
+		if err == nil {
+			err = runLoggedCommand(cmd, LoggedCommandTimeout, services...)
+		}
+
+		if err != nil {
+			return fmt.Errorf("The %s hook %q failed: %s", hook, command, err)
+		}
+	}
+
+	return nil
+}
+
+// ShellCommand builds a command that runs the given script through the
+// server's shell with the project's environment. Project hooks don't belong
+// to any one service, so they run from the home directory
+func (p *ProjectConfiguration) ShellCommand(script string) (*exec.Cmd, error) {
+	env := inheritedEnv()
+	for key, value := range p.Env {
+		env[key] = value
+	}
+
+	shell, args := getServerShell()
+	cmd := exec.Command(shell, append(append([]string{}, args...), "-c", script)...)
+	cmd.Dir = getHomeDir()
+	cmd.Env = envToList(env)
+
+	return cmd, nil
+}
+
+// runHooks runs one of the project's hooks. The output goes to every
+// service in the project since the hook concerns all of them
+func (p *ProjectConfiguration) runHooks(hook string, commands []string) error {
+	services := make([]ServiceInterface, 0, len(p.Services))
+	for _, service := range p.Services {
+		services = append(services, service.(ServiceInterface))
+	}
+
+	return runHooks(hook, commands, p.ShellCommand, services)
+}
+
+// runHooks runs one of the service's hooks in its working dir and with its
+// environment
+func (s *RunnableServiceConfiguration) runHooks(hook string, commands []string) error {
+	return runHooks(hook, commands, s.ShellCommand, []ServiceInterface{s})
+}
+
+// runPostStartHooks waits for the given process to be ready before running
+// the post_start hooks, as long as it's still the service's process by then
+func (s *RunnableServiceConfiguration) runPostStartHooks(process *ServiceProcess) {
+	if err := waitForService(context.Background(), s); err != nil {
+		logServiceMessage(s, "Not running the "+HookPostStart+" hooks: "+err.Error())
+		return
+	}
+
+	if s.getProcess() != process {
+		return
+	}
+
+	if err := s.runHooks(HookPostStart, s.PostStart); err != nil {
+		logServiceMessage(s, err.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collectLines returns every line in the service's log, oldest first
+func collectLines(service ServiceInterface) []string {
+	var lines []string
+	for entry := service.GetLogs().Root; entry != nil; entry = entry.Next {
+		if entry.Line != "" {
+			lines = append(lines, entry.Line)
+		}
+	}
+
+	return lines
+}
+
+func TestRunLoggedCommand(t *testing.T) {
+	first := &RunnableServiceConfiguration{Name: "first"}
+	second := &RunnableServiceConfiguration{Name: "second"}
+	cmd := exec.Command("sh", "-c", "echo out; echo err >&2")
+
+	if err := runLoggedCommand(cmd, time.Minute, first, second); err != nil {
+		t.Fatalf("Expected the command to succeed, got %s", err)
+	}
+
+	for _, service := range []ServiceInterface{first, second} {
+		lines := collectLines(service)
+		if len(lines) != 2 || !strings.Contains(strings.Join(lines, ","), "out") || !strings.Contains(strings.Join(lines, ","), "err") {
+			t.Errorf("Expected both output streams in the log of %s, got %q", service.GetName(), lines)
+		}
+	}
+
+	if err := runLoggedCommand(exec.Command("sh", "-c", "exit 2"), time.Minute, first); err == nil {
+		t.Error("Expected the failing command to report an error")
+	}
+}
+
+func TestRunHooksStopsAtFailure(t *testing.T) {
+	service := &RunnableServiceConfiguration{Name: "hooks"}
+	build := func(script string) (*exec.Cmd, error) {
+		return exec.Command("sh", "-c", script), nil
+	}
+
+	err := runHooks(HookPreStart, []string{"echo one", "false", "echo three"}, build, []ServiceInterface{service})
+	if err == nil || !strings.Contains(err.Error(), `"false"`) {
+		t.Fatalf("Expected the failing hook to be reported, got %v", err)
+	}
+
+	expected := []string{
+		ServiceLogPrefix + "Running " + HookPreStart + " hook: echo one",
+		"one",
+		ServiceLogPrefix + "Running " + HookPreStart + " hook: false",
+	}
+	if lines := collectLines(service); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected the hooks to stop at the failure, got %q", lines)
+	}
+}
+
+func TestRunLoggedCommandLongLine(t *testing.T) {
+	service := &RunnableServiceConfiguration{Name: "hooks"}
+	cmd := exec.Command("sh", "-c", "head -c 100000 /dev/zero | tr '\\0' x; echo; echo done")
+
+	result := make(chan error, 1)
+	go func() {
+		result <- runLoggedCommand(cmd, time.Minute, service)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Expected the command to succeed, got %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("The command hung on a line longer than 64KiB")
+	}
+
+	lines := collectLines(service)
+	if len(lines) != 2 || len(lines[0]) != 100000 || lines[1] != "done" {
+		t.Fatalf("Expected the long line and then done, got %d lines", len(lines))
+	}
+}
+
+func TestRunLoggedCommandTimeout(t *testing.T) {
+	service := &RunnableServiceConfiguration{Name: "hooks"}
+	cmd := exec.Command("sh", "-c", "echo started; sleep 30 & wait")
+
+	start := time.Now()
+	err := runLoggedCommand(cmd, 200*time.Millisecond, service)
+
+	if err == nil || !strings.HasPrefix(err.Error(), ErrorCommandTimedOut.Error()) {
+		t.Fatalf("Expected the command to time out, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the command to be killed promptly, took %s", elapsed)
+	}
+
+	if lines := collectLines(service); len(lines) != 1 || lines[0] != "started" {
+		t.Fatalf("Expected the output before the timeout to be logged, got %q", lines)
+	}
+}
@@ -1,11 +1,16 @@
 package main
 
+import "time"
+
 // ServiceInterface the generic service interface
 type ServiceInterface interface {
 	Accept(interface{}) bool
 	Create(newConfig map[string]interface{}, project *ProjectConfiguration) ServiceInterface
+	Fail(reason error)
+	GetDependencies() []string
 	GetID() string
 	GetLogs() *ServiceLog
+	GetName() string
 	GetProject() *ProjectConfiguration
 	GetState() string
 	IsMatch(interface{}) bool
@@ -13,3 +18,76 @@ type ServiceInterface interface {
 	Stop() bool
 	Update(map[string]interface{}) error
 }
+
+// DelayedService is implemented by services that want the project to pause
+// before and after starting or stopping them
+type DelayedService interface {
+	GetDelayAfter() time.Duration
+	GetDelayBefore() time.Duration
+	SetWaiting(waiting bool)
+}
+
+// HistoricalService is implemented by services that keep a history of their
+// runs. The trigger and user say what and who the next call to Start is on
+// behalf of
+type HistoricalService interface {
+	GetHistory() *ServiceHistory
+	SetTrigger(trigger, user string)
+}
+
+// InspectableService is implemented by services whose processes can be
+// looked into, and killed one at a time
+type InspectableService interface {
+	GetProcesses() ([]*ProcessNode, error)
+	KillProcess(pid int, force bool) error
+}
+
+// InteractiveService is implemented by services that accept input on stdin
+type InteractiveService interface {
+	WriteInput(data []byte) error
+}
+
+// PortedService is implemented by services that listen on ports of their
+// own, whose fixed ports are offset by the index of each replica
+type PortedService interface {
+	GetReplicas() int
+	GetServicePorts() []*ServicePort
+}
+
+// RestartableService is implemented by services that have processes to wait
+// out when restarting. AwaitExit returns false if they didn't go in time
+type RestartableService interface {
+	AwaitExit() bool
+	Restart() bool
+}
+
+// ResizableService is implemented by services whose terminal can be resized
+type ResizableService interface {
+	Resize(cols, rows int) error
+}
+
+// ScalableService is implemented by services that can run several replicas
+// of themselves
+type ScalableService interface {
+	GetReplicas() int
+	Scale(replicas int) error
+}
+
+// SignalableService is implemented by services that can be sent signals. The
+// signal goes to the whole process group unless it's for the leader only
+type SignalableService interface {
+	Signal(name string, leaderOnly bool) error
+}
+
+// TaskService is implemented by services that run to completion rather
+// than staying up
+type TaskService interface {
+	GetExitCode() *int
+	Run() error
+}
+
+// DetailedService is implemented by services that can report runtime details
+// on top of their configuration
+type DetailedService interface {
+	GetDetails() map[string]interface{}
+}
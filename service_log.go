@@ -1,9 +1,18 @@
 package main
 
+import "sync"
+
+const (
+	// ServiceLogPrefix is prepended to any line that orchestra itself writes
+	// into a service log so it stands out from the service's own output
+	ServiceLogPrefix = "[orchestra] "
+)
+
 // ServiceLog the struct for the historical log messages for a service
 type ServiceLog struct {
 	Head        *ServiceLogEntry
 	LineCount   int
+	Mutex       sync.Mutex `json:"-"`
 	ReadPointer *ServiceLogEntry
 	Root        *ServiceLogEntry `json:"root"`
 }
@@ -16,6 +25,9 @@ type ServiceLogEntry struct {
 
 // Append appends a string to the logs
 func (s *ServiceLog) Append(msg string) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
 	entry := ServiceLogEntry{Line: msg}
 
 	if s.ReadPointer == nil {
@@ -38,3 +50,12 @@ func (s *ServiceLog) Append(msg string) {
 
 	s.LineCount++
 }
+
+// logServiceMessage writes an orchestra generated message into the service's
+// log and pushes it out to everyone watching
+func logServiceMessage(service ServiceInterface, msg string) {
+	if logs := service.GetLogs(); logs != nil {
+		logs.Append(ServiceLogPrefix + msg)
+		broadcastProcessData(service)
+	}
+}
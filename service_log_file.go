@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// LogDirName the directory under the orchestra home that detached
+	// services write their output to
+	LogDirName = "logs"
+
+	// LogFileBackups how many rotated log files are kept around
+	LogFileBackups = 3
+
+	// LogFileMaxSize how big a log file gets before it's rotated
+	LogFileMaxSize = 10 * 1024 * 1024
+
+	// LogFileReplaySize how much of the end of the log file gets replayed
+	// into the service log when a detached service is adopted
+	LogFileReplaySize = 256 * 1024
+
+	// LogTailInterval how often the log file is checked for more output
+	LogTailInterval = 250 * time.Millisecond
+)
+
+// getLogFilePath returns the file a detached service writes its output to
+func getLogFilePath(serviceID string) string {
+	return filepath.Join(getHomeDir(), DefaultHomePath, LogDirName, serviceID+".log")
+}
+
+// openLogFile opens the log file for the service to write to. It's opened
+// for appending, so that when the file gets truncated during a rotation
+// the service carries on from the start rather than leaving a hole
+func openLogFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
+
+// rotateLogFile moves the contents of the log file into the first backup,
+// shuffling the older backups along. The file is copied and truncated in
+// place since the service still has it open, so anything written in between
+// the two is lost
+func rotateLogFile(path string) error {
+	for i := LogFileBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	backup, err := os.Create(path + ".1")
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	if _, err = io.Copy(backup, source); err != nil {
+		return err
+	}
+
+	return os.Truncate(path, 0)
+}
+
+// StartLogTailer follows the log file of a detached process from the given
+// offset, rotating it once it gets too big. It keeps going until the
+// process has ended and the last of its output has been read
+func (s *ServiceProcess) StartLogTailer(offset int64) {
+	defer s.Readers.Done()
+
+	file, err := os.Open(s.LogPath)
+	if err != nil {
+		Error.Println("Could not open the log file for", s.Configuration.Name+":", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		Error.Println("Could not seek in the log file for", s.Configuration.Name+":", err)
+		return
+	}
+
+	Debug.Println("Starting log tailer for", s.Configuration.Name)
+	reader := bufio.NewReader(file)
+	partial := ""
+	ended := false
+
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+
+		if err == nil {
+			line = strings.TrimSuffix(partial+line, "\n")
+			partial = ""
+
+			s.Channel <- line
+			s.checkAlerts(line)
+			continue
+		}
+
+		// Hang on to half written lines until the rest of them shows up
+		partial += line
+
+		if ended {
+			if partial != "" {
+				s.Channel <- partial
+				s.checkAlerts(partial)
+			}
+
+			Debug.Println("Exiting the log tailer for", s.Configuration.Name)
+			return
+		}
+
+		select {
+		case <-s.Ended:
+			// Go around once more for whatever was written on the way out
+			ended = true
+			continue
+		case <-time.After(LogTailInterval):
+		}
+
+		if offset >= LogFileMaxSize {
+			if err := rotateLogFile(s.LogPath); err != nil {
+				Error.Println("Could not rotate the log file for", s.Configuration.Name+":", err)
+			}
+		}
+
+		// Start over from the top once the file has been truncated, whether
+		// we did it or someone else did
+		if info, err := file.Stat(); err == nil && info.Size() < offset {
+			file.Seek(0, io.SeekStart)
+			reader.Reset(file)
+			offset = 0
+			partial = ""
+		}
+	}
+}
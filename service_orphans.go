@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// OrphanPolicyAdopt orphans are tracked as if they were started by this
+	// orchestra, though their output can't be captured
+	OrphanPolicyAdopt = "adopt"
+
+	// OrphanPolicyReap orphans are stopped the same way the service would
+	// normally be stopped
+	OrphanPolicyReap = "reap"
+
+	// OrphanPollInterval how often adopted processes are checked on, since
+	// they can't be waited on like our own children
+	OrphanPollInterval = time.Second
+
+	// ProcessStateFileName the file under the orchestra home that the running
+	// service processes are recorded in
+	ProcessStateFileName = "processes.json"
+)
+
+var (
+	// RunningProcesses the record of every service process this orchestra
+	// has running. It only gets persisted once it's been loaded off of disk
+	RunningProcesses = &ProcessState{Processes: make(map[string]*ProcessRecord)}
+)
+
+// ProcessRecord what's remembered about a running service process, so that
+// it can be found again if orchestra goes down without stopping it. The
+// start time is in clock ticks since boot and tells the group leader apart
+// from anything that got its PID later on
+type ProcessRecord struct {
+	BootID    string         `json:"boot_id"`
+	Cgroup    string         `json:"cgroup,omitempty"`
+	LogPath   string         `json:"log_path,omitempty"`
+	Pgid      int            `json:"pgid"`
+	Ports     map[string]int `json:"ports,omitempty"`
+	Project   string         `json:"project,omitempty"`
+	Restarts  int            `json:"restarts"`
+	Service   string         `json:"service"`
+	StartTime int64          `json:"start_time"`
+	StartedAt time.Time      `json:"started_at"`
+	Trigger   string         `json:"trigger"`
+	User      string         `json:"user,omitempty"`
+}
+
+// ProcessState the running service processes, by service ID
+type ProcessState struct {
+	Mutex     sync.Mutex                `json:"-"`
+	Path      string                    `json:"-"`
+	Processes map[string]*ProcessRecord `json:"processes"`
+}
+
+// loadProcessState reads the process records left behind by the last run of
+// orchestra
+func loadProcessState() *ProcessState {
+	state := &ProcessState{
+		Path:      filepath.Join(getHomeDir(), DefaultHomePath, ProcessStateFileName),
+		Processes: make(map[string]*ProcessRecord)}
+
+	data, err := ioutil.ReadFile(state.Path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Error.Println("Could not read the process state:", err)
+		}
+
+		return state
+	}
+
+	if err = json.Unmarshal(data, state); err != nil {
+		Error.Println("Could not parse the process state:", err)
+	}
+
+	if state.Processes == nil {
+		state.Processes = make(map[string]*ProcessRecord)
+	}
+
+	return state
+}
+
+// Forget drops the record for the given service, as long as it's still the
+// record for the given process group
+func (p *ProcessState) Forget(serviceID string, pgid int) {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	if record, ok := p.Processes[serviceID]; ok && record.Pgid == pgid {
+		delete(p.Processes, serviceID)
+		p.save()
+	}
+}
+
+// Take hands over every record, leaving none behind
+func (p *ProcessState) Take() map[string]*ProcessRecord {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	records := p.Processes
+	p.Processes = make(map[string]*ProcessRecord)
+	p.save()
+
+	return records
+}
+
+// Track records the running process for the given service
+func (p *ProcessState) Track(serviceID string, record *ProcessRecord) {
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	p.Processes[serviceID] = record
+	p.save()
+}
+
+// save persists the records, which the caller must hold the lock for
+func (p *ProcessState) save() {
+	if p.Path == "" {
+		return
+	}
+
+	data, err := json.Marshal(p)
+	if err == nil {
+		err = ioutil.WriteFile(p.Path, data, 0644)
+	}
+
+	if err != nil {
+		Error.Println("Could not save the process state:", err)
+	}
+}
+
+// IsAlive tests whether the process group is still around and is still the
+// one that was recorded
+func (r *ProcessRecord) IsAlive() bool {
+	if r.BootID != readBootID() {
+		return false
+	}
+
+	members, err := listProcessGroup(r.Pgid)
+	if err != nil || len(members) == 0 {
+		return false
+	}
+
+	// The leader may have exited ahead of the rest of the group, but if its
+	// PID is still in use it had better be by the same process
+	if stat, err := readProcStat(r.Pgid); err == nil && stat.StartTime != r.StartTime {
+		return false
+	}
+
+	return true
+}
+
+// trackProcess records a freshly started process so it can be found again
+func trackProcess(s *ServiceProcess) {
+	config := s.Configuration
+	record := &ProcessRecord{
+		BootID:    readBootID(),
+		Cgroup:    s.Cgroup,
+		LogPath:   s.LogPath,
+		Pgid:      s.Pgid,
+		Ports:     config.AllocatedPorts,
+		Restarts:  s.Run.Restarts,
+		Service:   config.Name,
+		StartedAt: s.Run.StartedAt,
+		Trigger:   s.Run.Trigger,
+		User:      s.Run.User}
+
+	if config.Project != nil {
+		record.Project = config.Project.Name
+	}
+
+	if stat, err := readProcStat(s.Pgid); err == nil {
+		record.StartTime = stat.StartTime
+	}
+
+	RunningProcesses.Track(config.ID, record)
+}
+
+// forgetProcess drops the record of a process that has exited
+func forgetProcess(s *ServiceProcess) {
+	RunningProcesses.Forget(s.Configuration.ID, s.Pgid)
+}
+
+// findRunnableService looks up the runnable service, or replica of one, with
+// the given ID. Scheduled and task services are left out on purpose, since
+// there's no picking up where one of their runs left off, so their orphans
+// always get reaped. That's also why neither of them can be detached
+func findRunnableService(serviceID string) *RunnableServiceConfiguration {
+	for _, project := range Config.Projects {
+		for _, service := range project.Services {
+			if runnable, ok := service.(*RunnableServiceConfiguration); ok && runnable.ID == serviceID {
+				return runnable
+			}
+
+			if replica := matchReplica(service, serviceID); replica != nil {
+				return replica
+			}
+		}
+	}
+
+	return nil
+}
+
+// recoverOrphans deals with the service processes a previous orchestra left
+// running when it went down, according to the policy of their project.
+// Detached services are always adopted, since they were left running on
+// purpose, while the orphans of services that no longer exist are always
+// reaped. Reaping is done before returning, so the ports and such are free
+// again
+func recoverOrphans() {
+	RunningProcesses = loadProcessState()
+	wg := &sync.WaitGroup{}
+
+	for serviceID, record := range RunningProcesses.Take() {
+		if !record.IsAlive() {
+			continue
+		}
+
+		service := findRunnableService(serviceID)
+		if service != nil && service.Project != nil &&
+			(record.LogPath != "" || service.Project.GetOrphanPolicy() == OrphanPolicyAdopt) {
+			service.adopt(record)
+			continue
+		}
+
+		wg.Add(1)
+		go func(service *RunnableServiceConfiguration, record *ProcessRecord) {
+			defer wg.Done()
+			reapOrphan(service, record)
+		}(service, record)
+	}
+
+	wg.Wait()
+}
+
+// reapOrphan stops an orphaned process group the same way its service would
+// have been stopped, if we still know about the service
+func reapOrphan(service *RunnableServiceConfiguration, record *ProcessRecord) {
+	defaults := &RunnableServiceConfiguration{}
+	if service != nil {
+		defaults = service
+	}
+
+	sig, grace := defaults.GetStopSignal(), defaults.GetStopGracePeriod()
+	Info.Println("Reaping the orphaned process group", record.Pgid, "of", record.Service)
+
+	if err := syscall.Kill(-record.Pgid, sig); err != nil {
+		Error.Println("Could not signal the orphaned process group", record.Pgid, err)
+	}
+
+	deadline := time.Now().Add(grace)
+	for record.IsAlive() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if record.IsAlive() {
+		syscall.Kill(-record.Pgid, syscall.SIGKILL)
+	}
+
+	if record.Cgroup != "" {
+		os.Remove(record.Cgroup)
+	}
+
+	if service != nil {
+		logServiceMessage(service, fmt.Sprintf("Reaped process group %d, which a previous run of "+
+			"orchestra left running", record.Pgid))
+	}
+}
+
+// adopt takes over an orphaned process group of the service, showing the
+// service as running for as long as the group is around. The output of a
+// detached service picks up again from its log file, starting with a replay
+// of the tail end of it
+func (s *RunnableServiceConfiguration) adopt(record *ProcessRecord) {
+	run := &ServiceRun{
+		Restarts:  record.Restarts,
+		StartedAt: record.StartedAt,
+		Trigger:   record.Trigger,
+		User:      record.User}
+
+	process := &ServiceProcess{
+		Adopted:       true,
+		Cgroup:        record.Cgroup,
+		Configuration: s,
+		Exited:        make(chan struct{}),
+		Logs:          s.GetLogs(),
+		Mutex:         &sync.Mutex{},
+		Pgid:          record.Pgid,
+		Readers:       &sync.WaitGroup{},
+		Run:           run,
+		Running:       true}
+
+	s.Restarts = record.Restarts
+	s.setProcess(process)
+	s.setRunState(true, ServiceRunning)
+
+	// Hang on to the ports so they don't get handed to anyone else
+	s.AllocatedPorts = record.Ports
+	reservedPortsMutex.Lock()
+	for _, port := range record.Ports {
+		reservedPorts[port] = s.ID
+	}
+	reservedPortsMutex.Unlock()
+
+	RunningProcesses.Track(s.ID, record)
+
+	if record.LogPath != "" {
+		process.Channel = make(chan string, 1000)
+		process.Ended = make(chan struct{})
+		process.LogPath = record.LogPath
+
+		if info, err := os.Stat(record.LogPath); err == nil && info.Size() > LogFileReplaySize {
+			process.LogOffset = info.Size() - LogFileReplaySize
+		}
+
+		logServiceMessage(s, fmt.Sprintf("Adopted detached process group %d, following its output in %s",
+			record.Pgid, record.LogPath))
+
+		process.Readers.Add(1)
+		go process.StartChannelListener()
+		go process.StartLogTailer(process.LogOffset)
+	} else {
+		logServiceMessage(s, fmt.Sprintf("Adopted process group %d, which a previous run of orchestra "+
+			"left running. Its output can't be captured", record.Pgid))
+	}
+
+	go process.StartAdoptedExitListener()
+	go process.StartStatsListener()
+	s.startWatching()
+}
+
+// StartAdoptedExitListener polls an adopted process group until it's gone,
+// since it isn't our child to wait on, and then updates the service state.
+// There's no telling how it exited
+func (s *ServiceProcess) StartAdoptedExitListener() {
+	ticker := time.NewTicker(OrphanPollInterval)
+	for range ticker.C {
+		members, err := listProcessGroup(s.Pgid)
+		if err == nil && len(members) == 0 {
+			break
+		}
+	}
+	ticker.Stop()
+
+	if s.LogPath != "" {
+		close(s.Ended)
+		s.Readers.Wait()
+		s.Cleanup()
+	}
+
+	s.Configuration.setRunState(false, ServiceStopped)
+
+	Debug.Println("It appears that the adopted process for", s.Configuration.Name, "has exited")
+	s.finishExit("exit status unknown", nil)
+}
+
+// GetOrphanPolicy returns what to do with the processes a previous
+// orchestra left running for the project's services
+func (p *ProjectConfiguration) GetOrphanPolicy() string {
+	if p.OrphanPolicy == "" {
+		return OrphanPolicyReap
+	}
+
+	return p.OrphanPolicy
+}
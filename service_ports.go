@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// PortDefaultRangeEnd the last port that gets handed out to services
+	// that don't fix their ports
+	PortDefaultRangeEnd = 29999
+
+	// PortDefaultRangeStart the first port that gets handed out to services
+	// that don't fix their ports
+	PortDefaultRangeStart = 20000
+
+	// PortEnvName the variable the unnamed port is handed to the service in.
+	// Named ports get the name tacked on, as in PORT_ADMIN
+	PortEnvName = "PORT"
+)
+
+var (
+	// PortNameCleaner matches everything in a port name that can't go into an
+	// environment variable name
+	PortNameCleaner = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+	// reservedPorts the allocated ports, by the ID of the service they were
+	// allocated to, so two services never get handed the same one before
+	// either of them has gotten around to listening on it
+	reservedPorts = make(map[int]string)
+
+	// reservedPortsMutex guards the reserved ports
+	reservedPortsMutex sync.Mutex
+)
+
+// ServicePort a port the service listens on. A service either fixes the port,
+// in which case it has to be free for the service to start, or leaves it out
+// and gets one allocated from the free range
+type ServicePort struct {
+	Name string `json:"name,omitempty"`
+	Port int    `json:"port,omitempty"`
+}
+
+// GetEnvName returns the variable the port is handed to the service in
+func (p *ServicePort) GetEnvName() string {
+	if p.Name == "" {
+		return PortEnvName
+	}
+
+	name := strings.Trim(PortNameCleaner.ReplaceAllString(p.Name, "_"), "_")
+	return PortEnvName + "_" + strings.ToUpper(name)
+}
+
+// validatePorts makes sure every port is a real port and that no two of them
+// would end up in the same variable
+func validatePorts(ports []*ServicePort) error {
+	names := make(map[string]bool, len(ports))
+	for _, port := range ports {
+		if port == nil || port.Port < 0 || port.Port > 65535 || names[port.GetEnvName()] {
+			return ErrorInvalidPorts
+		}
+
+		names[port.GetEnvName()] = true
+	}
+
+	return nil
+}
+
+// getPortRange returns the range ports get allocated from
+func getPortRange() (int, int) {
+	if Config != nil && Config.Server.PortRangeStart > 0 && Config.Server.PortRangeEnd >= Config.Server.PortRangeStart {
+		return Config.Server.PortRangeStart, Config.Server.PortRangeEnd
+	}
+
+	return PortDefaultRangeStart, PortDefaultRangeEnd
+}
+
+// checkPortFree makes sure nothing is listening on the given port, naming
+// whoever is if something is
+func checkPortFree(port int) error {
+	listening, err := listListeningPorts()
+	if err != nil {
+		// Without /proc there's nothing to check against, so the service
+		// will find out for itself when it tries to listen
+		Debug.Println("Could not check port", port, "is free:", err)
+		return nil
+	}
+
+	inode, ok := listening[port]
+	if !ok {
+		return nil
+	}
+
+	if pid := findSocketOwner(inode); pid != 0 {
+		return fmt.Errorf("Port %d is already in use by PID %d (%s)", port, pid, readProcessName(pid))
+	}
+
+	return fmt.Errorf("Port %d is already in use by a process owned by another user", port)
+}
+
+// reservePort claims the given fixed port for the service, as long as no
+// other service has claimed it first and nothing is listening on it yet
+func reservePort(serviceID string, port int) error {
+	reservedPortsMutex.Lock()
+	defer reservedPortsMutex.Unlock()
+
+	if owner, ok := reservedPorts[port]; ok && owner != serviceID {
+		return fmt.Errorf("Port %d is already reserved by service %s", port, owner)
+	}
+
+	if err := checkPortFree(port); err != nil {
+		return err
+	}
+
+	reservedPorts[port] = serviceID
+	return nil
+}
+
+// allocatePort reserves a free port out of the range for the given service.
+// The previously allocated port is handed back out if it's still free, so
+// ports stay put across restarts
+func allocatePort(serviceID string, previous int) (int, error) {
+	reservedPortsMutex.Lock()
+	defer reservedPortsMutex.Unlock()
+
+	listening, err := listListeningPorts()
+	if err != nil {
+		Debug.Println("Could not check which ports are free:", err)
+		listening = make(map[int]uint64)
+	}
+
+	isFree := func(port int) bool {
+		owner, reserved := reservedPorts[port]
+		_, inUse := listening[port]
+		return !inUse && (!reserved || owner == serviceID)
+	}
+
+	start, end := getPortRange()
+	if previous >= start && previous <= end && isFree(previous) {
+		reservedPorts[previous] = serviceID
+		return previous, nil
+	}
+
+	// Start looking somewhere random so that separate orchestras sharing a
+	// machine don't all race for the bottom of the range
+	size := end - start + 1
+	offset := rand.Intn(size)
+	for i := 0; i < size; i++ {
+		port := start + (offset+i)%size
+		if isFree(port) {
+			reservedPorts[port] = serviceID
+			return port, nil
+		}
+	}
+
+	return 0, ErrorNoFreePorts
+}
+
+// releasePorts gives back every port that was allocated to the given service
+func releasePorts(serviceID string) {
+	reservedPortsMutex.Lock()
+	defer reservedPortsMutex.Unlock()
+
+	for port, owner := range reservedPorts {
+		if owner == serviceID {
+			delete(reservedPorts, port)
+		}
+	}
+}
+
+// GetPorts returns the port in each of the service's port variables. Ports
+// that are allocated only show up once the service has been started, while
+// fixed ones are offset by the replica index so that replicas don't clash
+func (s *RunnableServiceConfiguration) GetPorts() map[string]int {
+	ports := make(map[string]int, len(s.Ports))
+	for _, port := range s.Ports {
+		if port.Port != 0 {
+			ports[port.GetEnvName()] = port.Port + s.Index
+		} else if allocated, ok := s.AllocatedPorts[port.GetEnvName()]; ok {
+			ports[port.GetEnvName()] = allocated
+		}
+	}
+
+	return ports
+}
+
+// GetServicePorts returns the ports as configured, fixed or not
+func (s *RunnableServiceConfiguration) GetServicePorts() []*ServicePort {
+	return s.Ports
+}
+
+// assignPorts reserves every fixed port, and allocates the rest
+func (s *RunnableServiceConfiguration) assignPorts() error {
+	// Anything still reserved from the last start is up for grabs again,
+	// though allocatePort will hand back the same ports if it can
+	releasePorts(s.ID)
+
+	allocated := make(map[string]int)
+	for _, port := range s.Ports {
+		if port.Port != 0 {
+			if err := reservePort(s.ID, port.Port+s.Index); err != nil {
+				releasePorts(s.ID)
+				return err
+			}
+
+			continue
+		}
+
+		number, err := allocatePort(s.ID, s.AllocatedPorts[port.GetEnvName()])
+		if err != nil {
+			releasePorts(s.ID)
+			return err
+		}
+
+		allocated[port.GetEnvName()] = number
+	}
+
+	s.AllocatedPorts = allocated
+	return nil
+}
+
+// fixedPorts returns every port the given fixed ports end up on once each
+// replica has offset them by its index
+func fixedPorts(ports []*ServicePort, replicas int) []int {
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	var numbers []int
+	for _, port := range ports {
+		if port == nil || port.Port == 0 {
+			continue
+		}
+
+		for index := 0; index < replicas; index++ {
+			numbers = append(numbers, port.Port+index)
+		}
+	}
+
+	return numbers
+}
+
+// resolveFixedPorts works out the name, ports and replica count of the given
+// service (or raw service map). A map that leaves its ports or replicas out
+// keeps the ones of the service it updates
+func (p *ProjectConfiguration) resolveFixedPorts(src interface{}) (string, []*ServicePort, int) {
+	switch src.(type) {
+	case PortedService:
+		service := src.(PortedService)
+		return src.(ServiceInterface).GetName(), service.GetServicePorts(), service.GetReplicas()
+	case map[string]interface{}:
+		config := src.(map[string]interface{})
+
+		// Anything that doesn't decode gets turned away by the service's own
+		// Update anyway
+		var shim struct {
+			Name     string         `json:"name"`
+			Ports    []*ServicePort `json:"ports"`
+			Replicas int            `json:"replicas"`
+		}
+		data, _ := json.Marshal(config)
+		json.Unmarshal(data, &shim)
+
+		for _, s := range p.Services {
+			service, ok := s.(PortedService)
+			if !ok || !s.(ServiceInterface).IsMatch(config) {
+				continue
+			}
+
+			if shim.Name == "" {
+				shim.Name = s.(ServiceInterface).GetName()
+			}
+
+			if shim.Ports == nil {
+				shim.Ports = service.GetServicePorts()
+			}
+
+			if shim.Replicas == 0 {
+				shim.Replicas = service.GetReplicas()
+			}
+		}
+
+		return shim.Name, shim.Ports, shim.Replicas
+	}
+
+	return "", nil, 0
+}
+
+// validateFixedPorts makes sure no two of the given services (or raw service
+// maps) fix the same port, counting each replica's offset ports as well, so
+// that they don't find out only once they're started
+func (p *ProjectConfiguration) validateFixedPorts(services []interface{}) error {
+	owners := make(map[int]string)
+	for _, src := range services {
+		name, ports, replicas := p.resolveFixedPorts(src)
+
+		for _, port := range fixedPorts(ports, replicas) {
+			if owner, ok := owners[port]; ok {
+				return fmt.Errorf("%s: port %d is fixed by both %q and %q", ErrorDuplicatePorts, port, owner, name)
+			}
+
+			owners[port] = name
+		}
+	}
+
+	return nil
+}
+
+// validateServiceUpdate makes sure updating the given service of the project
+// with the given config leaves no fixed port claimed twice, and the services
+// still in an order they can be started in
+func (p *ProjectConfiguration) validateServiceUpdate(service ServiceInterface, config map[string]interface{}) error {
+	if p == nil {
+		return nil
+	}
+
+	services := make([]interface{}, len(p.Services))
+	dependencies := make([]interface{}, len(p.Services))
+	for i, s := range p.Services {
+		if s == service {
+			services[i] = config
+			dependencies[i] = mergeDependencyUpdate(service, config)
+		} else {
+			services[i] = s
+			dependencies[i] = s
+		}
+	}
+
+	if err := p.validateFixedPorts(services); err != nil {
+		return err
+	}
+
+	return validateServiceDependencies(dependencies)
+}
+
+// checkPort makes sure the mock's port is free
+func (m *MockeryServiceConfiguration) checkPort() error {
+	port, err := strconv.Atoi(strings.TrimPrefix(m.Port, ":"))
+	if err != nil {
+		// Leave it to the server to complain about a port that makes no sense
+		return nil
+	}
+
+	return checkPortFree(port)
+}
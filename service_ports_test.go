@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServicePortEnvNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"", "PORT"},
+		{"admin", "PORT_ADMIN"},
+		{"debug-ui", "PORT_DEBUG_UI"},
+		{" grpc.v2 ", "PORT_GRPC_V2"},
+	}
+
+	for _, test := range tests {
+		port := &ServicePort{Name: test.name}
+		if port.GetEnvName() != test.expected {
+			t.Errorf("Expected %q to go in %s, got %s", test.name, test.expected, port.GetEnvName())
+		}
+	}
+
+	if validatePorts([]*ServicePort{{Port: 8080}, {Name: "admin"}}) != nil {
+		t.Error("Expected distinct ports to be valid")
+	}
+
+	if validatePorts([]*ServicePort{{Port: 70000}}) != ErrorInvalidPorts {
+		t.Error("Expected an out of range port to be rejected")
+	}
+
+	if validatePorts([]*ServicePort{{Name: "debug-ui"}, {Name: "debug ui"}}) != ErrorInvalidPorts {
+		t.Error("Expected ports sharing a variable to be rejected")
+	}
+}
+
+func TestAllocatePort(t *testing.T) {
+	defer releasePorts("first")
+	defer releasePorts("second")
+
+	start, end := getPortRange()
+	first, err := allocatePort("first", 0)
+	if err != nil || first < start || first > end {
+		t.Fatalf("Expected a port in the range, got %d (%v)", first, err)
+	}
+
+	// The port stays reserved for its service across restarts
+	second, err := allocatePort("second", first)
+	if err != nil || second == first {
+		t.Errorf("Expected the second service to get a port of its own, got %d (%v)", second, err)
+	}
+
+	again, err := allocatePort("first", first)
+	if err != nil || again != first {
+		t.Errorf("Expected the service to get its previous port %d back, got %d (%v)", first, again, err)
+	}
+
+	releasePorts("first")
+	if port, err := allocatePort("second", first); err != nil || port != first {
+		t.Errorf("Expected the released port %d to be handed out again, got %d (%v)", first, port, err)
+	}
+}
+
+func TestGetPorts(t *testing.T) {
+	// Allocated ports only show up once they've been handed out
+	service := &RunnableServiceConfiguration{
+		AllocatedPorts: map[string]int{"PORT_ADMIN": 20005},
+		Ports:          []*ServicePort{{Port: 8080}, {Name: "admin"}, {Name: "metrics"}}}
+
+	expected := map[string]int{"PORT": 8080, "PORT_ADMIN": 20005}
+	if ports := service.GetPorts(); !reflect.DeepEqual(ports, expected) {
+		t.Errorf("Expected %v, got %v", expected, ports)
+	}
+
+	// Replicas offset the fixed ports by their index
+	service.Index = 2
+	expected["PORT"] = 8082
+	if ports := service.GetPorts(); !reflect.DeepEqual(ports, expected) {
+		t.Errorf("Expected %v for the replica, got %v", expected, ports)
+	}
+}
+
+func TestReservePortOncePerService(t *testing.T) {
+	const port = 47123
+	defer releasePorts("first")
+	defer releasePorts("second")
+
+	if err := reservePort("first", port); err != nil {
+		t.Fatalf("Expected the port to be free, got %s", err)
+	}
+
+	if err := reservePort("first", port); err != nil {
+		t.Errorf("Expected the owner to be able to reserve the port again, got %s", err)
+	}
+
+	if err := reservePort("second", port); err == nil {
+		t.Error("Expected a second service to be refused the reserved port")
+	}
+
+	// Allocated ports have to steer clear of it too
+	if number, err := allocatePort("second", port); err == nil && number == port {
+		t.Error("Expected the reserved port not to be allocated to another service")
+	}
+
+	releasePorts("first")
+	if err := reservePort("second", port); err != nil {
+		t.Errorf("Expected the released port to be free again, got %s", err)
+	}
+}
+
+func TestValidateFixedPorts(t *testing.T) {
+	project := &ProjectConfiguration{Name: "ports"}
+	api := &RunnableServiceConfiguration{
+		ID:       "api",
+		Name:     "api",
+		Ports:    []*ServicePort{{Port: 8080}},
+		Project:  project,
+		Replicas: 2}
+	worker := &RunnableServiceConfiguration{
+		ID:      "worker",
+		Name:    "worker",
+		Ports:   []*ServicePort{{Port: 9090}},
+		Project: project}
+	project.Services = []interface{}{api, worker}
+
+	tests := []struct {
+		name     string
+		services []interface{}
+		valid    bool
+	}{
+		{"distinct ports", []interface{}{
+			map[string]interface{}{"id": "api"},
+			map[string]interface{}{"name": "web", "ports": []interface{}{map[string]interface{}{"port": 8082}}},
+		}, true},
+		{"same port", []interface{}{
+			map[string]interface{}{"name": "one", "ports": []interface{}{map[string]interface{}{"port": 3000}}},
+			map[string]interface{}{"name": "two", "ports": []interface{}{map[string]interface{}{"port": 3000}}},
+		}, false},
+		{"replica offset", []interface{}{
+			map[string]interface{}{"id": "api"},
+			map[string]interface{}{"name": "web", "ports": []interface{}{map[string]interface{}{"port": 8081}}},
+		}, false},
+		{"replicas of one service", []interface{}{
+			map[string]interface{}{"name": "web", "replicas": 2, "ports": []interface{}{
+				map[string]interface{}{"port": 4000},
+				map[string]interface{}{"name": "admin", "port": 4001}}},
+		}, false},
+		{"swapped ports", []interface{}{
+			map[string]interface{}{"id": "api", "ports": []interface{}{map[string]interface{}{"port": 9090}}},
+			map[string]interface{}{"id": "worker", "ports": []interface{}{map[string]interface{}{"port": 8080}}},
+		}, true},
+	}
+
+	for _, test := range tests {
+		err := project.validateFixedPorts(test.services)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected the ports to be valid, got %s", test.name, err)
+		} else if !test.valid && (err == nil || !strings.HasPrefix(err.Error(), ErrorDuplicatePorts.Error())) {
+			t.Errorf("%s: expected the duplicate ports to be rejected, got %v", test.name, err)
+		}
+	}
+
+	if api.Ports[0].Port != 8080 {
+		t.Errorf("Expected validating to leave the service's ports alone, got %d", api.Ports[0].Port)
+	}
+
+	// Scaling api up to three replicas would put its last one on 8082
+	worker.Ports = []*ServicePort{{Port: 8082}}
+	if err := api.Scale(3); err == nil {
+		t.Error("Expected scaling into another service's port to be rejected")
+	}
+
+	if api.GetReplicas() != 2 {
+		t.Errorf("Expected the rejected scale to leave 2 replicas, got %d", api.GetReplicas())
+	}
+}
+
+func TestUpdateRangeChecksReplicatedPorts(t *testing.T) {
+	service := &RunnableServiceConfiguration{
+		Name:     "api",
+		Ports:    []*ServicePort{{Port: 8080}},
+		Replicas: 2}
+
+	// The second replica would end up on 65536
+	err := service.Update(map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 65535}}})
+	if err != ErrorInvalidPorts {
+		t.Errorf("Expected the out of range ports to be rejected, got %v", err)
+	}
+
+	if service.Ports[0].Port != 8080 {
+		t.Errorf("Expected the rejected update to leave the ports alone, got %d", service.Ports[0].Port)
+	}
+
+	if err := service.Update(map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 65534}}}); err != nil {
+		t.Errorf("Expected ports that fit every replica to be accepted, got %s", err)
+	}
+}
+
+func TestActionErrorStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{ErrorInvalidReplicas, http.StatusBadRequest},
+		{ErrorInvalidPorts, http.StatusBadRequest},
+		{ErrorCannotModifyService, http.StatusConflict},
+		{fmt.Errorf("%s: port %d is fixed by both %q and %q", ErrorDuplicatePorts, 80, "a", "b"), http.StatusConflict},
+		{errors.New("disk on fire"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		if status := actionErrorStatus(test.err); status != test.expected {
+			t.Errorf("Expected %q to be reported as %d, got %d", test.err, test.expected, status)
+		}
+	}
+}
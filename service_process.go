@@ -3,25 +3,45 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
-	"log"
+	"os"
 	"os/exec"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 )
 
-// ServiceProcess the struct for holding the currently running service process
+// ServiceProcess the struct for holding the currently running service process.
+// Processes adopted from a previous orchestra have no command or pipes, just
+// the process group, and detached ones have their log file in place of pipes
 type ServiceProcess struct {
+	Adopted       bool
+	Alerts        *AlertCheck
+	Cgroup        string
 	Channel       chan string
 	Command       *exec.Cmd
 	Configuration *RunnableServiceConfiguration
+	Ended         chan struct{}
 	Error         io.ReadCloser
+	Exited        chan struct{}
 	Input         io.WriteCloser
+	LogOffset     int64
+	LogPath       string
 	Logs          *ServiceLog
 	Mutex         *sync.Mutex
 	Output        io.ReadCloser
+	Pgid          int
+	Pty           *os.File
+	Readers       *sync.WaitGroup
+	Readiness     *ReadinessCheck
+	Run           *ServiceRun
 	Running       bool
+	Stats         *ProcessStats
+	StopSignal    syscall.Signal
+	Stopping      bool
 }
 
 // NewServiceProcess creates a new, runnable service process
@@ -31,11 +51,13 @@ func NewServiceProcess(cmd *exec.Cmd, config *RunnableServiceConfiguration, read
 		Channel:       make(chan string, 1000),
 		Command:       cmd,
 		Configuration: config,
-		Logs:          &ServiceLog{},
+		Logs:          config.GetLogs(),
 		Mutex:         &sync.Mutex{},
 		Error:         err,
+		Exited:        make(chan struct{}),
 		Input:         writer,
 		Output:        reader,
+		Readers:       &sync.WaitGroup{},
 		Running:       true}
 }
 
@@ -54,7 +76,17 @@ func (s *ServiceProcess) Cleanup() error {
 			}
 		}()
 		defer close(s.Channel)
-		s.Channel = nil
+
+		// A terminal is both ends of the conversation, so there's only the
+		// one thing to close
+		if s.Pty != nil {
+			return s.Pty.Close()
+		}
+
+		// The tailer closes the log file itself
+		if s.LogPath != "" {
+			return nil
+		}
 
 		// Cleanup the pipe readers and writers for the process
 		err = s.Error.Close()
@@ -76,55 +108,306 @@ func (s *ServiceProcess) Cleanup() error {
 	return err
 }
 
+// isRunning returns whether the process is still going
+func (s *ServiceProcess) isRunning() bool {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	return s.Running
+}
+
+// isStopping returns whether the process has been asked to stop
+func (s *ServiceProcess) isStopping() bool {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	return s.Stopping
+}
+
+// setStopping flags that the process has been asked to stop, so its exit
+// isn't mistaken for a crash
+func (s *ServiceProcess) setStopping() {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	s.Stopping = true
+}
+
 // Kill stops the service process if it's running and then issues the cleanup command
 // on the various channels and pipes
 func (s *ServiceProcess) Kill() {
 	Debug.Println("Kill called against", s.Configuration.Name)
-	err := syscall.Kill(-s.Command.Process.Pid, syscall.SIGKILL)
+	s.setStopping()
+	err := syscall.Kill(-s.Pgid, syscall.SIGKILL)
 	if err != nil {
 		Error.Println("Could not kill process:", err)
 	}
 }
 
+// Stop asks the process group to shut down with the given signal, then waits
+// for it to exit and escalates to a SIGKILL once the grace period runs out
+func (s *ServiceProcess) Stop(sig syscall.Signal, grace time.Duration) {
+	Debug.Println("Stop called against", s.Configuration.Name)
+	s.StopSignal = sig
+	s.setStopping()
+
+	err := syscall.Kill(-s.Pgid, sig)
+	if err != nil {
+		Error.Println("Could not signal process:", err)
+		logServiceMessage(s.Configuration, "Could not send "+signalName(sig)+", sending SIGKILL")
+		s.Kill()
+		return
+	}
+
+	logServiceMessage(s.Configuration, fmt.Sprintf("Sent %s, waiting up to %s for the process to exit",
+		signalName(sig), grace))
+
+	select {
+	case <-s.Exited:
+		logServiceMessage(s.Configuration, "Process exited after "+signalName(sig))
+	case <-time.After(grace):
+		logServiceMessage(s.Configuration, fmt.Sprintf("Process did not exit within %s of %s, sending SIGKILL",
+			grace, signalName(sig)))
+		s.Kill()
+	}
+}
+
 // Start initializes the process and starts it up, returning a new pointer
-// to a service process
-func (s *ServiceProcess) Start(config *RunnableServiceConfiguration) *ServiceProcess {
-	command := exec.Command("bash")
+// to a service process or the reason the process could not be launched. The
+// run gets filled in once the process exits
+func (s *ServiceProcess) Start(config *RunnableServiceConfiguration, run *ServiceRun) (*ServiceProcess, error) {
+	env, err := config.GetEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	// Exec mode runs the real process directly so its PID, signals and exit
+	// code are its own, otherwise we script a shell over stdin
+	var command *exec.Cmd
+	if config.IsExecMode() {
+		if len(config.Argv) == 0 {
+			return nil, ErrorMissingArgv
+		}
+
+		command = exec.Command(config.Argv[0], config.Argv[1:]...)
+	} else {
+		shell, args := config.GetShell()
+
+		// A shell with a terminal on stdin turns interactive and echoes the
+		// script back at us, and a detached one has no stdin to speak of, so
+		// hand it the script up front instead
+		if config.Pty || config.Detached {
+			script := append([]string{"cd " + config.WorkingDir}, config.Commands...)
+			args = append(append([]string{}, args...), "-c", strings.Join(script, "\n"))
+		}
+
+		command = exec.Command(shell, args...)
+	}
+
+	command.Dir = config.WorkingDir
+	command.Env = envToList(env)
+
+	if config.Pty && config.Detached {
+		return nil, ErrorDetachedTerminal
+	}
+
+	if config.Detached {
+		s, err = newDetachedProcess(command, config)
+	} else if config.Pty {
+		s, err = newPtyProcess(command, config)
+	} else {
+		s, err = newPipeProcess(command, config)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.Run = run
+
+	if config.Readiness != nil {
+		s.Readiness, err = NewReadinessCheck(config.Readiness)
+		if err != nil {
+			s.closeFiles()
+			return nil, err
+		}
+	}
+
+	if len(config.Alerts) > 0 {
+		s.Alerts, err = NewAlertCheck(config.Alerts)
+		if err != nil {
+			s.closeFiles()
+			return nil, err
+		}
+	}
+
+	// The limits have to be in place before the process starts, or anything
+	// it forks in the meantime gets away without them
+	var cgroupDir *os.File
+	if config.Limits != nil {
+		cgroupDir = s.prepareLimits(command, config.Limits)
+	}
+
+	// Launch the process up front so a failure to even start is reported
+	// straight back to whoever asked for it
+	err = command.Start()
+	if cgroupDir != nil {
+		cgroupDir.Close()
+	}
+
+	if err != nil {
+		Error.Println("Error while attempting to start the command:", err)
+		s.closeFiles()
+		s.releaseCgroup()
+		return nil, err
+	}
+
+	// The process is the leader of its own group, so its PID is the group ID
+	s.Pgid = command.Process.Pid
+
+	trackProcess(s)
+
+	switch {
+	case s.LogPath != "":
+		// The child has its own copy of the log file, and follows it from
+		// where it stood before the process started
+		command.Stdout.(*os.File).Close()
+
+		s.Readers.Add(1)
+		go s.StartLogTailer(s.LogOffset)
+	case s.Pty != nil:
+		// The child has its own copy of the terminal now, and holding on to
+		// ours would keep us from ever seeing the end of the output
+		command.Stdin.(*os.File).Close()
+
+		s.Readers.Add(1)
+		go s.StartOutputListener()
+	default:
+		s.Readers.Add(2)
+		go s.StartErrorListener()
+		go s.StartOutputListener()
+	}
+
+	go s.StartChannelListener()
+	go s.StartExitListener()
+	go s.StartStatsListener()
+
+	if !config.IsExecMode() && !config.Pty && !config.Detached {
+		// TODO: refactor this away from the config since it's been moved into the process
+		// Now, start this guy up
+		s.WriteString("cd " + s.Configuration.WorkingDir)
+		for _, cmd := range s.Configuration.Commands {
+			s.WriteString(cmd)
+		}
+
+		// Now make sure the terminal quits when finished (ie if it isn't blocking)
+		s.WriteString("exit")
+	}
+
+	return s, nil
+}
+
+// newPipeProcess wires the command up to plain stdin, stdout and stderr pipes
+func newPipeProcess(command *exec.Cmd, config *RunnableServiceConfiguration) (*ServiceProcess, error) {
 	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	inputPipe, err := command.StdinPipe()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	outputPipe, err := command.StdoutPipe()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	errorPipe, err := command.StderrPipe()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	s = NewServiceProcess(command, config, outputPipe, errorPipe, inputPipe)
+	return NewServiceProcess(command, config, outputPipe, errorPipe, inputPipe), nil
+}
 
-	go s.StartChannelListener()
-	go s.StartErrorListener()
-	go s.StartOutputListener()
-	go s.StartExitListener()
+// newPtyProcess wires the command up to a freshly allocated pseudo-terminal,
+// which it gets as its stdin, stdout, stderr and controlling terminal
+func newPtyProcess(command *exec.Cmd, config *RunnableServiceConfiguration) (*ServiceProcess, error) {
+	master, slave, err := openPty()
+	if err != nil {
+		return nil, err
+	}
+
+	cols, rows := config.GetPtySize()
+	if err = setPtySize(master, uint16(cols), uint16(rows)); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, err
+	}
+
+	command.Stdin = slave
+	command.Stdout = slave
+	command.Stderr = slave
+	command.SysProcAttr = ptySysProcAttr()
+
+	s := NewServiceProcess(command, config, master, nil, master)
+	s.Pty = master
+
+	return s, nil
+}
+
+// newDetachedProcess points the output of the command at the service's log
+// file and gives it a session of its own, so that it carries on running
+// without a hitch if we go away
+func newDetachedProcess(command *exec.Cmd, config *RunnableServiceConfiguration) (*ServiceProcess, error) {
+	path := getLogFilePath(config.ID)
+	file, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	command.Stdout = file
+	command.Stderr = file
+	command.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	s := NewServiceProcess(command, config, nil, nil, nil)
+	s.Ended = make(chan struct{})
+	s.LogOffset = info.Size()
+	s.LogPath = path
+
+	return s, nil
+}
 
-	// TODO: refactor this away from the config since it's been moved into the process
-	// Now, start this guy up
-	s.WriteString("cd " + s.Configuration.WorkingDir)
-	for _, cmd := range s.Configuration.Commands {
-		s.WriteString(cmd)
+// closeFiles releases the terminal or log file of a process that never got
+// going
+func (s *ServiceProcess) closeFiles() {
+	if s.Pty != nil {
+		s.Pty.Close()
+		s.Command.Stdin.(*os.File).Close()
 	}
 
-	// Now make sure the terminal quits when finished (ie if it isn't blocking)
-	s.WriteString("exit")
+	if s.LogPath != "" {
+		s.Command.Stdout.(*os.File).Close()
+	}
+}
+
+// Resize changes the window size of the process' terminal, which also lets
+// the process know about it with a SIGWINCH
+func (s *ServiceProcess) Resize(cols, rows int) error {
+	if s.Pty == nil {
+		return ErrorServiceNotTerminal
+	}
 
-	return s
+	if cols <= 0 || rows <= 0 || cols > 0xffff || rows > 0xffff {
+		return ErrorInvalidPtySize
+	}
+
+	return setPtySize(s.Pty, uint16(cols), uint16(rows))
 }
 
 // StartChannelListener begins listening to the service processes channel in order
@@ -136,24 +419,23 @@ func (s *ServiceProcess) StartChannelListener() {
 		}
 	}()
 
-	// Halt once we're not running anymore
-	for s.Running && s.Channel != nil {
-		select {
-		case line, ok := <-s.Channel:
-			if !ok {
-				Debug.Println("It appears the channel closed, exiting the listener")
-				break
-			}
-
-			ForEachActiveUser(func(user *User) {
-				user.WriteLogMessage(line, s.Configuration)
-			})
+	// Drain the channel until cleanup closes it so we don't lose the last
+	// few lines
+	for line := range s.Channel {
+		if s.Readiness != nil {
+			s.Readiness.Observe(line)
 		}
+
+		s.Logs.Append(line)
+		broadcastProcessData(s.Configuration)
 	}
+
+	Debug.Println("It appears the channel closed, exiting the listener")
 }
 
 // StartOutputListener starts the stdin listener
 func (s *ServiceProcess) StartOutputListener() {
+	defer s.Readers.Done()
 	reader := bufio.NewReader(s.Output)
 
 	Info.Println("Starting stdout listener for", s.Configuration.Name)
@@ -165,12 +447,19 @@ func (s *ServiceProcess) StartOutputListener() {
 			break
 		}
 
+		// Terminals end their lines with a carriage return as well
+		if s.Pty != nil {
+			lines = bytes.TrimSuffix(lines, []byte("\r"))
+		}
+
 		s.Channel <- string(lines)
+		s.checkAlerts(string(lines))
 	}
 }
 
 // StartErrorListener starts the stderr listener
 func (s *ServiceProcess) StartErrorListener() {
+	defer s.Readers.Done()
 	reader := bufio.NewReader(s.Error)
 
 	Debug.Println("Starting error listener for", s.Configuration.Name)
@@ -182,50 +471,109 @@ func (s *ServiceProcess) StartErrorListener() {
 		}
 
 		s.Channel <- string(lines)
+		s.checkAlerts(string(lines))
 	}
 }
 
-// StartExitListener starts the gofunc that listens for the running process
-// to complete its execution
+// StartExitListener listens for the running process to complete its
+// execution and then updates the service state accordingly
 func (s *ServiceProcess) StartExitListener() {
-	err := s.Command.Start()
-	if err != nil {
-		Error.Println("Error while attempting to start the command:", err)
+	Debug.Println("Waiting for process exit for", s.Configuration.Name)
+
+	// Wait closes the pipes, so let the readers hit the end of the output
+	// first or we'll lose whatever the process wrote on its way out. The
+	// log file never ends though, so the tailer has to be told instead
+	var err error
+	if s.LogPath != "" {
+		err = s.Command.Wait()
+		close(s.Ended)
+		s.Readers.Wait()
 	} else {
-		// Now start the process listener
-		go func() {
-			Debug.Println("Waiting for process exit for", s.Configuration.Name)
-			err := s.Command.Wait()
-
-			// Command must have exited, update our status
-			s.Configuration.Running = false
-			s.Configuration.State = ServiceStopped
-
-			if err != nil {
-				// Only signal that it's dead rather than stopped if this wasn't us
-				// issuing a sigkill
-				if err.Error() != "signal: killed" {
-					s.Configuration.State = ServiceDead
-					Error.Println(s.Configuration.Name, err)
-				}
-			}
+		s.Readers.Wait()
+		err = s.Command.Wait()
+	}
 
-			Debug.Println("It appears that the process for", s.Configuration.Name, "has exited")
-			// Now, cleanup the running process, shutoff its channel and pipes, then make
-			// sure we mark it as done
-			s.Cleanup()
-			s.Running = false
+	// Command must have exited, update our status
+	state := ServiceStopped
+	reason := "exit status 0"
 
-			ForEachActiveUser(func(user *User) {
-				user.WriteStatusMessage(s.Configuration.State, s.Configuration)
-			})
-		}()
+	if err != nil {
+		reason = err.Error()
+
+		// Only signal that it's dead rather than stopped if this wasn't us
+		// shutting it down
+		if !s.isStopping() || !s.exitedFromStop(err) {
+			state = ServiceDead
+			Error.Println(s.Configuration.Name, err)
+		}
+	}
+	s.Configuration.setRunState(false, state)
+
+	Debug.Println("It appears that the process for", s.Configuration.Name, "has exited")
+	// Now, cleanup the running process, shutoff its channel and pipes, then make
+	// sure we mark it as done
+	s.Cleanup()
+	s.finishExit(reason, s.Command.ProcessState)
+}
+
+// finishExit wraps up after the process has exited, recording the run and
+// letting everyone know about it before handing the exit over to the restart
+// policy
+func (s *ServiceProcess) finishExit(reason string, processState *os.ProcessState) {
+	// A process killed for going over its limits didn't fail on its own,
+	// so make sure that's clear
+	if limit := s.releaseCgroup(); limit != "" {
+		s.Configuration.setState(ServiceOOMKilled)
+		s.Run.Limit = limit
+		logServiceMessage(s.Configuration, "Process was killed for going over its "+limit+" limit")
+	}
+
+	serviceStateMutex.Lock()
+	s.Running = false
+	serviceStateMutex.Unlock()
+	forgetProcess(s)
+
+	state := s.Configuration.getState()
+	s.Run.Finish(state, processState)
+	s.Configuration.recordRun(s.Run)
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(state, s.Configuration)
+	})
+	close(s.Exited)
+
+	// Only exits we didn't ask for are up for an automatic restart
+	if !s.isStopping() {
+		s.Configuration.handleExit(reason)
+	}
+}
+
+// exitedFromStop tests whether the exit error is what we'd expect from a
+// process we asked to stop, ie it went down to the stop signal (or the kill
+// we escalated to) rather than failing on its way out
+func (s *ServiceProcess) exitedFromStop(err error) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
 	}
+
+	if status.Signaled() {
+		return status.Signal() == s.StopSignal || status.Signal() == syscall.SIGKILL
+	}
+
+	// Shells report a child that died to a signal as 128 + the signal number
+	return status.ExitStatus() == 128+int(s.StopSignal) ||
+		status.ExitStatus() == 128+int(syscall.SIGKILL)
 }
 
 // Write writes the specified data to the service configuration if it's running
 func (s *ServiceProcess) Write(data []byte) bool {
-	if s.Running {
+	if s.isRunning() && s.Input != nil {
 		_, err := s.Input.Write(data)
 		if err != nil {
 			Error.Println(err)
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// ProcessNode a single process of a service's process group, along with the
+// processes of the group it started. RSS is in bytes
+type ProcessNode struct {
+	Children  []*ProcessNode `json:"children"`
+	Command   string         `json:"command"`
+	PID       int            `json:"pid"`
+	PPID      int            `json:"ppid"`
+	RSS       int64          `json:"rss"`
+	StartedAt time.Time      `json:"started_at"`
+	State     string         `json:"state"`
+}
+
+// buildProcessTree walks /proc for every live process in the given group and
+// arranges them by parent. Anything whose parent isn't in the group, like
+// the leader or a process orphaned by its parent, ends up at the top
+func buildProcessTree(pgid int) ([]*ProcessNode, error) {
+	pids, err := listProcessGroup(pgid)
+	if err != nil {
+		return nil, err
+	}
+
+	bootTime, err := readBootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int64(os.Getpagesize())
+	nodes := make(map[int]*ProcessNode, len(pids))
+	for _, pid := range pids {
+		// Processes come and go while we look, so just skip the ones we miss
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+
+		nodes[pid] = &ProcessNode{
+			Children:  []*ProcessNode{},
+			Command:   readCommandLine(pid),
+			PID:       pid,
+			PPID:      stat.PPID,
+			RSS:       stat.RSSPages * pageSize,
+			StartedAt: bootTime.Add(time.Duration(stat.StartTime) * time.Second / ProcClockTicks),
+			State:     stat.State}
+	}
+
+	roots := []*ProcessNode{}
+	for _, pid := range pids {
+		node, ok := nodes[pid]
+		if !ok {
+			continue
+		}
+
+		if parent, ok := nodes[node.PPID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	sortProcessNodes(roots)
+	return roots, nil
+}
+
+// sortProcessNodes puts the nodes, and all of their children, in PID order
+func sortProcessNodes(nodes []*ProcessNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].PID < nodes[j].PID
+	})
+
+	for _, node := range nodes {
+		sortProcessNodes(node.Children)
+	}
+}
+
+// GetProcesses returns the tree of processes the service is running
+func (s *RunnableServiceConfiguration) GetProcesses() ([]*ProcessNode, error) {
+	process := s.runningProcess()
+	if process == nil {
+		return nil, ErrorServiceNotRunning
+	}
+
+	return buildProcessTree(process.Pgid)
+}
+
+// KillProcess kills a single process of the service, like a stuck worker,
+// leaving the rest of the service running. It gets SIGTERM, or SIGKILL when
+// forced
+func (s *RunnableServiceConfiguration) KillProcess(pid int, force bool) error {
+	process := s.runningProcess()
+	if process == nil {
+		return ErrorServiceNotRunning
+	}
+
+	if pid == process.Pgid {
+		return ErrorCannotKillLeader
+	}
+
+	// Only ever go after processes that are still part of the service, since
+	// the PID may well have been handed to something else by now
+	stat, err := readProcStat(pid)
+	if err != nil || pid <= 0 || stat.Pgrp != process.Pgid || stat.State == "Z" {
+		return ErrorProcessNotInService
+	}
+
+	sig := syscall.SIGTERM
+	if force {
+		sig = syscall.SIGKILL
+	}
+
+	command := readCommandLine(pid)
+	if err = syscall.Kill(pid, sig); err != nil {
+		return err
+	}
+
+	logServiceMessage(s, fmt.Sprintf("Sent %s to process %d (%s)", signalName(sig), pid, command))
+	return nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// ReplicaEnvName the variable each replica is handed its index in, which
+	// is 0 for the service itself
+	ReplicaEnvName = "INSTANCE_INDEX"
+
+	// ServiceMaxReplicas the most replicas a service can be scaled to
+	ServiceMaxReplicas = 64
+)
+
+// validateReplicas makes sure the replica count is in range and that the
+// fixed ports, once offset by the replica index, are still real ports
+func validateReplicas(replicas int, ports []*ServicePort) error {
+	if replicas < 1 || replicas > ServiceMaxReplicas {
+		return ErrorInvalidReplicas
+	}
+
+	for _, port := range ports {
+		if port.Port != 0 && port.Port+replicas-1 > 65535 {
+			return ErrorInvalidPorts
+		}
+	}
+
+	return nil
+}
+
+// listServiceInstances returns every service of the project along with each
+// of their replicas
+func listServiceInstances(project *ProjectConfiguration) []ServiceInterface {
+	services := make([]ServiceInterface, 0, len(project.Services))
+	for _, service := range project.Services {
+		services = append(services, service.(ServiceInterface))
+
+		if runnable, ok := service.(*RunnableServiceConfiguration); ok {
+			for _, replica := range runnable.getInstances() {
+				services = append(services, replica)
+			}
+		}
+	}
+
+	return services
+}
+
+// matchReplica returns the replica of the service with the given ID, if the
+// service is a runnable one and has such a replica
+func matchReplica(service interface{}, replicaID string) *RunnableServiceConfiguration {
+	if runnable, ok := service.(*RunnableServiceConfiguration); ok {
+		return runnable.findReplica(replicaID)
+	}
+
+	return nil
+}
+
+// GetReplicas returns how many copies of the service get run
+func (s *RunnableServiceConfiguration) GetReplicas() int {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	return s.replicaCount()
+}
+
+// replicaCount returns how many copies of the service get run, for callers
+// that already hold serviceStateMutex
+func (s *RunnableServiceConfiguration) replicaCount() int {
+	if s.Replicas < 1 {
+		return 1
+	}
+
+	return s.Replicas
+}
+
+// getInstances returns the replicas of the service past the first, as they
+// are right now
+func (s *RunnableServiceConfiguration) getInstances() []*RunnableServiceConfiguration {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	return append([]*RunnableServiceConfiguration{}, s.Instances...)
+}
+
+// IsReplica returns whether this is a replica of another service rather than
+// a service in its own right
+func (s *RunnableServiceConfiguration) IsReplica() bool {
+	return s.Parent != nil
+}
+
+// Scale changes how many copies of the service get run. While the service
+// is running, replicas are started or stopped to match straight away, with
+// the newest ones going first
+func (s *RunnableServiceConfiguration) Scale(replicas int) error {
+	if s.IsReplica() {
+		return ErrorServiceNotScalable
+	}
+
+	if err := validateReplicas(replicas, s.Ports); err != nil {
+		return err
+	}
+
+	// The extra replicas' ports mustn't run into another service's
+	scale := map[string]interface{}{"id": s.ID, "replicas": replicas}
+	if err := s.Project.validateServiceUpdate(s, scale); err != nil {
+		return err
+	}
+
+	serviceStateMutex.Lock()
+	previous := s.replicaCount()
+	s.Replicas = replicas
+
+	removed := []*RunnableServiceConfiguration{}
+	for len(s.Instances) >= replicas {
+		removed = append(removed, s.Instances[len(s.Instances)-1])
+		s.Instances = s.Instances[:len(s.Instances)-1]
+	}
+	serviceStateMutex.Unlock()
+
+	logServiceMessage(s, fmt.Sprintf("Scaled from %d to %d replicas", previous, replicas))
+
+	stopReplicas(removed)
+	for _, replica := range removed {
+		releasePorts(replica.ID)
+	}
+
+	if s.isRunning() {
+		s.startReplicas(TriggerManual, "")
+	}
+
+	return nil
+}
+
+// findReplica returns the replica with the given ID, as long as the service
+// is meant to have it. Replicas are made as they're asked for, so that the
+// processes of one left running by a previous orchestra can be found again
+func (s *RunnableServiceConfiguration) findReplica(replicaID string) *RunnableServiceConfiguration {
+	prefix := s.ID + "-"
+	if s.ID == "" || !strings.HasPrefix(replicaID, prefix) {
+		return nil
+	}
+
+	index, err := strconv.Atoi(strings.TrimPrefix(replicaID, prefix))
+	if err != nil || index < 1 {
+		return nil
+	}
+
+	// The count has to be checked under the same lock the replica is made
+	// under, or a scale down could slip in between
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	if index >= s.replicaCount() {
+		return nil
+	}
+
+	return s.makeReplica(index)
+}
+
+// getReplica returns the replica with the given index, making it and any
+// missing ones before it as needed
+func (s *RunnableServiceConfiguration) getReplica(index int) *RunnableServiceConfiguration {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	return s.makeReplica(index)
+}
+
+// makeReplica does the work of getReplica, for callers that already hold
+// serviceStateMutex
+func (s *RunnableServiceConfiguration) makeReplica(index int) *RunnableServiceConfiguration {
+	for len(s.Instances) < index {
+		s.Instances = append(s.Instances, s.newReplica(len(s.Instances)+1, nil))
+	}
+
+	return s.Instances[index-1]
+}
+
+// newReplica builds the replica of the service with the given index, which
+// shares the service's configuration but nothing about how it's running. The
+// log, history and ports of the previous replica with the index carry over
+func (s *RunnableServiceConfiguration) newReplica(index int, previous *RunnableServiceConfiguration) *RunnableServiceConfiguration {
+	replica := *s
+	replica.ID = fmt.Sprintf("%s-%d", s.ID, index)
+	replica.Index = index
+	replica.Name = fmt.Sprintf("%s #%d", s.Name, index)
+	replica.Parent = s
+	replica.Replicas = 0
+	replica.Instances = nil
+
+	// The service watches its files on behalf of all of its replicas
+	replica.Watch = nil
+	replica.Watcher = nil
+
+	replica.AllocatedPorts = nil
+	replica.History = nil
+	replica.Logs = nil
+	replica.Process = nil
+	replica.RecentExits = nil
+	replica.RestartTimer = nil
+	replica.Restarts = 0
+	replica.Running = false
+	replica.State = ServiceStopped
+	replica.Requester = ""
+	replica.Trigger = ""
+
+	if previous != nil {
+		replica.AllocatedPorts = previous.AllocatedPorts
+		replica.History = previous.History
+		replica.Logs = previous.Logs
+	}
+
+	return &replica
+}
+
+// startReplicas starts each replica past the first that isn't running yet,
+// bringing its configuration up to date with the service's beforehand
+func (s *RunnableServiceConfiguration) startReplicas(trigger, user string) {
+	for index := 1; index < s.GetReplicas(); index++ {
+		replica := s.getReplica(index)
+		if replica.isRunning() {
+			continue
+		}
+
+		replica.cancelRestart()
+
+		// Anything looking at the replica's state has to see the old one or
+		// the new one, not a mix of the two
+		serviceStateMutex.Lock()
+		*replica = *s.newReplica(index, replica)
+		serviceStateMutex.Unlock()
+
+		replica.freshStart(trigger, user)
+	}
+}
+
+// restartReplicasForChanges restarts every replica once the service's files
+// have changed
+func (s *RunnableServiceConfiguration) restartReplicasForChanges() {
+	for _, replica := range s.getInstances() {
+		replica.restartForChanges()
+	}
+}
+
+// stopReplicas stops the given replicas all at once, since each of them may
+// take the whole grace period to go down. It returns whether any of them
+// had anything to stop
+func stopReplicas(replicas []*RunnableServiceConfiguration) bool {
+	wg := &sync.WaitGroup{}
+	stopped := false
+
+	for _, replica := range replicas {
+		if !replica.isRunning() && replica.getState() != ServiceRestarting {
+			continue
+		}
+
+		stopped = true
+		wg.Add(1)
+		go func(replica *RunnableServiceConfiguration) {
+			defer wg.Done()
+			replica.Stop()
+		}(replica)
+	}
+
+	wg.Wait()
+	return stopped
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestScaleWhileFindingReplicas(t *testing.T) {
+	project := &ProjectConfiguration{Name: "replicas"}
+	service := &RunnableServiceConfiguration{ID: "web", Name: "web", Project: project}
+	project.Services = []interface{}{service}
+
+	done := make(chan struct{})
+	started := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		close(started)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if replica := service.findReplica("web-2"); replica != nil && replica.Index != 2 {
+					t.Errorf("Expected web-2 to be the replica with index 2, got %d", replica.Index)
+				}
+				listServiceInstances(project)
+			}
+		}
+	}()
+
+	// Make sure the lookups are under way before scaling
+	<-started
+	for i := 0; i < 200; i++ {
+		if err := service.Scale(2 + i%4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if err := service.Scale(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if instances := service.getInstances(); len(instances) > 1 {
+		t.Errorf("Expected at most one replica past the first, got %d", len(instances))
+	}
+
+	if service.findReplica("web-2") != nil {
+		t.Error("Expected no replica past the replica count to be found")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// RepositoryStatus the state of the git checkout a service runs out of
+type RepositoryStatus struct {
+	Branch         string `json:"branch"`
+	BranchMismatch bool   `json:"branch_mismatch,omitempty"`
+	Dirty          bool   `json:"dirty"`
+	ExpectedBranch string `json:"expected_branch,omitempty"`
+}
+
+// expandHome expands a leading ~ in a path to the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(getHomeDir(), path[1:])
+	}
+
+	return path
+}
+
+// gitCommand builds a git command that runs inside the service's working dir
+func (s *RunnableServiceConfiguration) gitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.GetWorkingDir()
+
+	return cmd
+}
+
+// isGitRepository tests whether the working dir is the top of a git
+// checkout. Being somewhere inside of another checkout doesn't count, or a
+// service meant to be cloned into a dir under some other repository would
+// never get its clone
+func (s *RunnableServiceConfiguration) isGitRepository() bool {
+	out, err := s.gitCommand("rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return false
+	}
+
+	// git hands back the real path, so the working dir has to be one too
+	dir, err := filepath.EvalSymlinks(s.GetWorkingDir())
+	if err != nil {
+		return false
+	}
+
+	return filepath.Clean(strings.TrimSpace(string(out))) == filepath.Clean(dir)
+}
+
+// GetBranch returns the current branch
+func (s *RunnableServiceConfiguration) GetBranch() string {
+	out, err := s.gitCommand("rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		Error.Println("Error while getting the branch of", s.Name+":", err)
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// GetRepositoryStatus returns the branch and dirty status of the working
+// dir, or nil if it isn't a git checkout
+func (s *RunnableServiceConfiguration) GetRepositoryStatus() *RepositoryStatus {
+	if !s.isGitRepository() {
+		return nil
+	}
+
+	status := &RepositoryStatus{
+		Branch:         s.GetBranch(),
+		ExpectedBranch: s.Branch}
+	status.BranchMismatch = s.Branch != "" && status.Branch != s.Branch
+
+	if out, err := s.gitCommand("status", "--porcelain").Output(); err == nil {
+		status.Dirty = len(strings.TrimSpace(string(out))) > 0
+	}
+
+	return status
+}
+
+// cloneRepository clones the service's repository into its working dir,
+// which has to be missing or empty. Local paths and bare repositories work
+// just as well as remote URLs
+func (s *RunnableServiceConfiguration) cloneRepository() error {
+	dir := s.GetWorkingDir()
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+		return ErrorWorkingDirNotEmpty
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	args := []string{"clone"}
+	if s.Branch != "" {
+		args = append(args, "--branch", s.Branch)
+	}
+	args = append(args, expandHome(s.Repository), dir)
+
+	logServiceMessage(s, "Cloning "+s.Repository+" into "+dir)
+
+	// The working dir may not exist yet, so clone from outside of it
+	cmd := exec.Command("git", args...)
+	if err := runLoggedCommand(cmd, LoggedCommandTimeout, s); err != nil {
+		return fmt.Errorf("Could not clone %q: %s", s.Repository, err)
+	}
+
+	return nil
+}
+
+// prepareRepository makes sure the working dir holds the service's
+// repository, checking out the configured branch if asked to and warning
+// when some other branch is checked out
+func (s *RunnableServiceConfiguration) prepareRepository() error {
+	if s.Repository != "" && !s.isGitRepository() {
+		if err := s.cloneRepository(); err != nil {
+			return err
+		}
+	}
+
+	if s.Branch == "" || !s.isGitRepository() {
+		return nil
+	}
+
+	current := s.GetBranch()
+	if current != s.Branch && s.CheckoutBranch {
+		logServiceMessage(s, fmt.Sprintf("Checking out %s (currently on %s)", s.Branch, current))
+
+		if err := runLoggedCommand(s.gitCommand("checkout", s.Branch), LoggedCommandTimeout, s); err != nil {
+			return fmt.Errorf("Could not check out %q: %s", s.Branch, err)
+		}
+
+		current = s.GetBranch()
+	}
+
+	if current != s.Branch {
+		logServiceMessage(s, fmt.Sprintf("Warning: %s is checked out, but the service is configured for %s",
+			current, s.Branch))
+	}
+
+	return nil
+}
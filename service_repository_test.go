@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsGitRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Skip("git is not available:", err)
+	}
+
+	nested := filepath.Join(dir, "services", "api")
+	if err = os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir      string
+		expected bool
+	}{
+		{dir, true},
+		{nested, false},
+		{filepath.Join(dir, "missing"), false},
+	}
+
+	for _, test := range tests {
+		service := &RunnableServiceConfiguration{WorkingDir: test.dir}
+		if service.isGitRepository() != test.expected {
+			t.Errorf("Expected %s being a repository to be %t", test.dir, test.expected)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import "time"
+
+const (
+	// ServiceDefaultStatsInterval the default number of seconds between
+	// resource usage samples of a running service
+	ServiceDefaultStatsInterval = 2
+)
+
+// GetStatsInterval returns how often running services get sampled
+func (s *ServerConfiguration) GetStatsInterval() time.Duration {
+	if s.StatsInterval <= 0 {
+		return ServiceDefaultStatsInterval * time.Second
+	}
+
+	return time.Duration(s.StatsInterval) * time.Second
+}
+
+// GetStats returns the most recent resource usage sample of the process,
+// which is nil until the first sample has been taken
+func (s *ServiceProcess) GetStats() *ProcessStats {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	return s.Stats
+}
+
+// StartStatsListener samples the resource usage of the whole process group
+// until the process exits, and streams each sample to the active users
+func (s *ServiceProcess) StartStatsListener() {
+	interval := DefaultConfiguration.Server.GetStatsInterval()
+	if Config != nil {
+		interval = Config.Server.GetStatsInterval()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Grab a baseline right away so the first real sample has CPU usage
+	s.sampleStats()
+
+	for {
+		select {
+		case <-s.Exited:
+			return
+		case <-ticker.C:
+		}
+
+		if stats := s.sampleStats(); stats != nil {
+			ForEachActiveUser(func(user *User) {
+				user.WriteStatsMessage(stats, s.Configuration)
+			})
+		}
+	}
+}
+
+// sampleStats takes a new sample of the process group and keeps hold of it
+func (s *ServiceProcess) sampleStats() *ProcessStats {
+	stats, err := sampleProcessGroup(s.Pgid, s.GetStats())
+	if err != nil {
+		Debug.Println("Could not sample the resource usage of", s.Configuration.Name+":", err)
+		return nil
+	}
+
+	s.Mutex.Lock()
+	s.Stats = stats
+	s.Mutex.Unlock()
+
+	return stats
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	// ErrorSignalNotAllowed the error for when a signal is sent to a service
+	// that doesn't allow it
+	ErrorSignalNotAllowed = errors.New("The signal is not allowed for this service")
+
+	// ErrorUnknownSignal the error for when a signal name can't be matched
+	ErrorUnknownSignal = errors.New("The signal is not recognized")
+
+	// ServiceDefaultAllowedSignals the signals that can be sent to a service
+	// that doesn't say otherwise. These are the ones daemons conventionally
+	// do something useful with, like reloading or dumping their state
+	ServiceDefaultAllowedSignals = []string{"SIGHUP", "SIGINT", "SIGQUIT", "SIGTERM", "SIGUSR1", "SIGUSR2"}
+
+	// SignalNames the set of signals that can be referred to by name
+	SignalNames = map[string]syscall.Signal{
+		"SIGABRT":  syscall.SIGABRT,
+		"SIGCONT":  syscall.SIGCONT,
+		"SIGHUP":   syscall.SIGHUP,
+		"SIGINT":   syscall.SIGINT,
+		"SIGKILL":  syscall.SIGKILL,
+		"SIGQUIT":  syscall.SIGQUIT,
+		"SIGSTOP":  syscall.SIGSTOP,
+		"SIGTERM":  syscall.SIGTERM,
+		"SIGTSTP":  syscall.SIGTSTP,
+		"SIGUSR1":  syscall.SIGUSR1,
+		"SIGUSR2":  syscall.SIGUSR2,
+		"SIGWINCH": syscall.SIGWINCH,
+	}
+)
+
+// parseSignal converts a signal name (SIGTERM, TERM, term) or number into
+// the matching signal
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+
+	if num, err := strconv.Atoi(name); err == nil {
+		for _, sig := range SignalNames {
+			if int(sig) == num {
+				return sig, nil
+			}
+		}
+
+		return 0, ErrorUnknownSignal
+	}
+
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	if sig, ok := SignalNames[name]; ok {
+		return sig, nil
+	}
+
+	return 0, ErrorUnknownSignal
+}
+
+// signalName returns the conventional name for the given signal
+func signalName(sig syscall.Signal) string {
+	for name, known := range SignalNames {
+		if known == sig {
+			return name
+		}
+	}
+
+	return "signal " + strconv.Itoa(int(sig))
+}
+
+// validateSignals makes sure every signal in the list is one we know
+func validateSignals(names []string) error {
+	for _, name := range names {
+		if _, err := parseSignal(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllowedSignals returns the signals that can be sent to the service
+func (s *RunnableServiceConfiguration) GetAllowedSignals() []string {
+	if s.AllowedSignals == nil {
+		return ServiceDefaultAllowedSignals
+	}
+
+	return s.AllowedSignals
+}
+
+// IsSignalAllowed tests whether the signal can be sent to the service
+func (s *RunnableServiceConfiguration) IsSignalAllowed(sig syscall.Signal) bool {
+	for _, name := range s.GetAllowedSignals() {
+		if allowed, err := parseSignal(name); err == nil && allowed == sig {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Signal sends the named signal to the service's process group, or just to
+// the leader of it, as long as the service allows the signal
+func (s *RunnableServiceConfiguration) Signal(name string, leaderOnly bool) error {
+	process := s.runningProcess()
+	if process == nil {
+		return ErrorServiceNotRunning
+	}
+
+	sig, err := parseSignal(name)
+	if err != nil {
+		return err
+	}
+
+	if !s.IsSignalAllowed(sig) {
+		return ErrorSignalNotAllowed
+	}
+
+	target := "process group"
+	if leaderOnly {
+		target = "process"
+	}
+
+	if err = process.Signal(sig, leaderOnly); err != nil {
+		logServiceMessage(s, "Could not send "+signalName(sig)+" to the "+target+": "+err.Error())
+		return err
+	}
+
+	logServiceMessage(s, "Sent "+signalName(sig)+" to the "+target)
+	return nil
+}
+
+// Signal sends the signal to the process group, or just to its leader
+func (s *ServiceProcess) Signal(sig syscall.Signal, leaderOnly bool) error {
+	if leaderOnly {
+		return syscall.Kill(s.Pgid, sig)
+	}
+
+	return syscall.Kill(-s.Pgid, sig)
+}
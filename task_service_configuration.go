@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+)
+
+// TaskServiceConfiguration a one-shot job, like a database migration or a
+// seed script, which runs to completion rather than staying up. Each run is
+// launched exactly like a runnable service, and ends up either succeeded or
+// failed depending on how it exited. How the last run went is part of the
+// run state, so it's guarded by serviceStateMutex too
+type TaskServiceConfiguration struct {
+	RunnableServiceConfiguration
+
+	ExitCode *int   `json:"-"`
+	Result   string `json:"-"`
+	Type     string `json:"type"`
+}
+
+func (s *TaskServiceConfiguration) Accept(src interface{}) bool {
+	switch src.(type) {
+	case *TaskServiceConfiguration:
+		return true
+	case map[string]interface{}:
+		config := src.(map[string]interface{})
+		if val, ok := config["type"]; ok {
+			if val.(string) == TypeTaskService {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *TaskServiceConfiguration) Create(newConfig map[string]interface{}, project *ProjectConfiguration) ServiceInterface {
+	task := &TaskServiceConfiguration{}
+	task.Update(newConfig)
+	task.Project = project
+
+	return task
+}
+
+// GetDetails returns the runtime details of the service for the API, which
+// includes how the last run exited
+func (s *TaskServiceConfiguration) GetDetails() map[string]interface{} {
+	details := s.RunnableServiceConfiguration.GetDetails()
+	if exitCode := s.GetExitCode(); exitCode != nil {
+		details["exit_code"] = *exitCode
+	}
+
+	return details
+}
+
+// GetExitCode returns the exit code of the last run, which is nil if it
+// hasn't finished or went down to a signal
+func (s *TaskServiceConfiguration) GetExitCode() *int {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	return s.ExitCode
+}
+
+// GetState returns the state of the current run, or how the last one turned
+// out if there isn't one going
+func (s *TaskServiceConfiguration) GetState() string {
+	serviceStateMutex.RLock()
+	defer serviceStateMutex.RUnlock()
+
+	if s.Running || s.State == ServiceWaiting {
+		return s.State
+	}
+
+	if s.Result == "" {
+		return ServicePending
+	}
+
+	return s.Result
+}
+
+// Restart stops the task if it's running and runs it again
+func (s *TaskServiceConfiguration) Restart() bool {
+	return s.restart(s.Stop, s.Start)
+}
+
+// Run runs the task again, as long as it isn't already running
+func (s *TaskServiceConfiguration) Run() error {
+	if s.isRunning() {
+		// Whoever asked for this run mustn't get the credit for the next one
+		s.SetTrigger("", "")
+		return ErrorServiceAlreadyRunning
+	}
+
+	if !s.Start() {
+		return ErrorTaskNotStarted
+	}
+
+	return nil
+}
+
+// Scale refuses to scale the task, since running the same one-shot job
+// several times over makes no sense
+func (s *TaskServiceConfiguration) Scale(replicas int) error {
+	return ErrorServiceNotScalable
+}
+
+// Start runs the task
+func (s *TaskServiceConfiguration) Start() bool {
+	trigger, user := s.Trigger, s.Requester
+	if trigger == "" {
+		trigger = TriggerManual
+	}
+	s.Trigger, s.Requester = "", ""
+
+	if s.isRunning() {
+		return false
+	}
+
+	// Anyone waiting on the task has to wait for this run now
+	s.setResult(nil, ServicePending)
+
+	if !s.freshStart(trigger, user) {
+		s.finish(nil, ServiceFailed, "Task could not be started")
+		return false
+	}
+
+	go s.awaitCompletion(s.getProcess())
+	return true
+}
+
+// Stop stops the task if it's running, which counts as a failure since it
+// never got to finish
+func (s *TaskServiceConfiguration) Stop() bool {
+	if !s.isRunning() {
+		return false
+	}
+
+	s.stopProcess()
+	releasePorts(s.ID)
+	return true
+}
+
+// awaitCompletion waits for a run to finish and records how it went
+func (s *TaskServiceConfiguration) awaitCompletion(process *ServiceProcess) {
+	<-process.Exited
+
+	// There's no restart coming to hand the ports on to
+	releasePorts(s.ID)
+
+	run := process.Run
+	exitCode := run.ExitCode
+
+	switch {
+	case process.isStopping():
+		s.finish(exitCode, ServiceFailed, "Task was stopped before it finished")
+	case run.Limit != "":
+		s.finish(exitCode, ServiceFailed, "Task was killed for going over its "+run.Limit+" limit")
+	case run.Signal != "":
+		s.finish(exitCode, ServiceFailed, "Task was killed by "+run.Signal)
+	case exitCode == nil:
+		s.finish(exitCode, ServiceFailed, "Task exited without an exit code")
+	case *exitCode != 0:
+		s.finish(exitCode, ServiceFailed, fmt.Sprintf("Task failed with exit code %d", *exitCode))
+	default:
+		s.finish(exitCode, ServiceSucceeded, "Task succeeded")
+	}
+}
+
+// setResult records the exit code and result of the last run
+func (s *TaskServiceConfiguration) setResult(exitCode *int, result string) {
+	serviceStateMutex.Lock()
+	defer serviceStateMutex.Unlock()
+
+	s.ExitCode = exitCode
+	s.Result = result
+}
+
+// finish records how the task turned out and lets everyone know
+func (s *TaskServiceConfiguration) finish(exitCode *int, result, msg string) {
+	s.setResult(exitCode, result)
+	logServiceMessage(s, msg)
+
+	ForEachActiveUser(func(user *User) {
+		user.WriteStatusMessage(s.GetState(), s)
+	})
+}
+
+// Update updates the service configuration
+func (s *TaskServiceConfiguration) Update(newConfig map[string]interface{}) error {
+	if detached, _ := newConfig["detached"].(bool); detached {
+		return ErrorDetachedNotSupported
+	}
+
+	if err := s.RunnableServiceConfiguration.Update(newConfig); err != nil {
+		return err
+	}
+
+	// A task that fails gets run again by hand, since a restart policy or a
+	// readiness probe make no sense for something that isn't meant to stay
+	// up, and it only ever runs the once
+	s.Readiness = nil
+	s.Replicas = 0
+	s.RestartPolicy = nil
+
+	s.Type = TypeTaskService
+	return nil
+}
@@ -89,6 +89,7 @@ func (u *User) handleMessage(message *IncomingSocketMessage) {
 			if claims.VerifyExpiresAt(time.Now().Unix(), true) &&
 				claims.VerifyNotBefore(time.Now().Unix(), true) {
 				for _, entry := range message.Data {
+					entry.User = u.Username
 					processCommand(entry, u)
 				}
 			} else {
@@ -130,13 +131,18 @@ func (u *User) ParseTokenString(tokenString string) *jwt.Token {
 	return nil
 }
 
-// ResetSubscription resets the reader
+// ResetSubscription resets the reader. Since service logs are kept across
+// runs, the reader is moved to the current end of the log so only new lines
+// get sent
 func (u *User) ResetSubscription(s interface{}) {
 	service := s.(ServiceInterface)
 
 	if projSub, ok := u.SubscribedProjects[service.GetProject().Name]; ok {
 		if servSub, ok := projSub.SubscribedServices[service.GetID()]; ok {
 			servSub.ReadPointer = nil
+			if service.GetLogs() != nil {
+				servSub.ReadPointer = service.GetLogs().Head
+			}
 			projSub.SubscribedServices[service.GetID()] = servSub
 		} else {
 			var headPointer *ServiceLogEntry
@@ -199,9 +205,9 @@ func (u *User) Subscribe(project *ProjectConfiguration) {
 		Project:            project,
 		SubscribedServices: make(map[string]ServiceSubscription)}
 
-	for _, service := range project.Services {
+	for _, service := range listServiceInstances(project) {
 		var headPointer *ServiceLogEntry
-		wrapper := service.(ServiceInterface)
+		wrapper := service
 
 		if wrapper.GetLogs() != nil {
 			headPointer = wrapper.GetLogs().Root
@@ -299,7 +305,9 @@ func (u *User) WriteServiceData(s interface{}) {
 
 				// u.SubscribedProjects[service.GetProject().Name] = projectSubscription
 				// TODO: migrate WriteLogMessage to operate as a batch send
-				u.WriteLogMessage(blob, service)
+				if blob != "" {
+					u.WriteLogMessage(blob, service)
+				}
 			}
 		}
 	}
@@ -309,7 +317,7 @@ func (u *User) WriteServiceData(s interface{}) {
 func (u *User) WriteProjectMessage(project *ProjectConfiguration) {
 	u.WriteJSON("project_update_message", ProjectUpdateMessage{
 		ProjectID: project.ID,
-		Project:   project,
+		Project:   buildProjectPayload(project),
 		Status:    "updated",
 		Type:      "project_update_message",
 	})
@@ -319,7 +327,7 @@ func (u *User) WriteProjectMessage(project *ProjectConfiguration) {
 func (u *User) WriteRemovalMessage(project *ProjectConfiguration) {
 	u.WriteJSON("project_removal_message", ProjectUpdateMessage{
 		ProjectID: project.ID,
-		Project:   project,
+		Project:   buildProjectPayload(project),
 		Status:    "removed",
 		Type:      "project_removal_message",
 	})
@@ -337,6 +345,57 @@ func (u *User) WriteStatusMessage(status string, s interface{}) {
 	})
 }
 
+// WriteAlertMessage writes an alert raised by a given service
+func (u *User) WriteAlertMessage(alert, line string, s interface{}) {
+	service := s.(ServiceInterface)
+
+	u.WriteJSON("service_alert_message", ServiceAlertMessage{
+		Alert:     alert,
+		ID:        service.GetID(),
+		Line:      line,
+		Name:      service.GetName(),
+		ProjectID: service.GetProject().ID,
+		Type:      "service_alert_message",
+	})
+}
+
+// WriteHistoryMessage writes the given runs of a service to the socket
+func (u *User) WriteHistoryMessage(status string, runs []*ServiceRun, s interface{}) {
+	service := s.(ServiceInterface)
+
+	u.WriteJSON("service_history_message", ServiceHistoryMessage{
+		ID:        service.GetID(),
+		ProjectID: service.GetProject().ID,
+		Runs:      runs,
+		Status:    status,
+		Type:      "service_history_message",
+	})
+}
+
+// WriteProcessesMessage writes the process tree of a service to the socket
+func (u *User) WriteProcessesMessage(processes []*ProcessNode, s interface{}) {
+	service := s.(ServiceInterface)
+
+	u.WriteJSON("service_processes_message", ServiceProcessesMessage{
+		ID:        service.GetID(),
+		ProjectID: service.GetProject().ID,
+		Processes: processes,
+		Type:      "service_processes_message",
+	})
+}
+
+// WriteStatsMessage writes the resource usage of a given service
+func (u *User) WriteStatsMessage(stats *ProcessStats, s interface{}) {
+	service := s.(ServiceInterface)
+
+	u.WriteJSON("service_stats_message", ServiceStatsMessage{
+		ID:        service.GetID(),
+		ProjectID: service.GetProject().ID,
+		Stats:     stats,
+		Type:      "service_stats_message",
+	})
+}
+
 // WriteLogMessage writes a log message for a service to the specified socket
 func (u *User) WriteLogMessage(data string, s interface{}) {
 	service := s.(ServiceInterface)
@@ -0,0 +1,122 @@
+// +build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// inotifyMask the events we want to hear about for each directory
+	inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+		syscall.IN_MODIFY | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+)
+
+// inotifyNotifier a file notifier backed by inotify
+type inotifyNotifier struct {
+	closeOnce sync.Once
+	done      chan struct{}
+	events    chan fileEvent
+	fd        int
+	file      *os.File
+	mutex     sync.Mutex
+	watches   map[int32]string
+}
+
+// newFileNotifier creates a new inotify instance. It's non-blocking so that
+// the runtime can poll it, which in turn lets closing it end the reader
+func newFileNotifier() (fileNotifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &inotifyNotifier{
+		done:    make(chan struct{}),
+		events:  make(chan fileEvent, 100),
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[int32]string)}
+	go n.read()
+
+	return n, nil
+}
+
+// Add starts watching the given directory (but not its subdirectories)
+func (n *inotifyNotifier) Add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+
+	n.mutex.Lock()
+	n.watches[int32(wd)] = dir
+	n.mutex.Unlock()
+
+	return nil
+}
+
+// Close stops all of the watches, and the reader along with them even if
+// nobody is draining the events any more
+func (n *inotifyNotifier) Close() error {
+	var err error
+	n.closeOnce.Do(func() {
+		close(n.done)
+		err = n.file.Close()
+	})
+
+	return err
+}
+
+// Events returns the channel that changes get delivered on
+func (n *inotifyNotifier) Events() <-chan fileEvent {
+	return n.events
+}
+
+// read turns the raw inotify events into file events until the notifier is
+// closed
+func (n *inotifyNotifier) read() {
+	defer close(n.events)
+	buffer := make([]byte, 64*1024)
+
+	for {
+		count, err := n.file.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[start:start+int(event.Len)]), "\x00")
+			offset = start + int(event.Len)
+
+			n.mutex.Lock()
+			dir, ok := n.watches[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(n.watches, event.Wd)
+			}
+			n.mutex.Unlock()
+
+			if !ok || name == "" {
+				continue
+			}
+
+			ev := fileEvent{
+				Created: event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0,
+				IsDir:   event.Mask&syscall.IN_ISDIR != 0,
+				Path:    filepath.Join(dir, name)}
+
+			select {
+			case n.events <- ev:
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
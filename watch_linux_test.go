@@ -0,0 +1,124 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readerRunning tells whether any inotify reader goroutine is still alive
+func readerRunning() bool {
+	buffer := make([]byte, 1<<20)
+	stacks := string(buffer[:runtime.Stack(buffer, true)])
+
+	return strings.Contains(stacks, "(*inotifyNotifier).read")
+}
+
+func TestInotifyReportsChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notifier, err := newFileNotifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notifier.Close()
+
+	if err = notifier.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "changed.txt")
+	if err = ioutil.WriteFile(path, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-notifier.Events():
+		if event.Path != path || !event.Created || event.IsDir {
+			t.Errorf("Expected the file to show up as created, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event for the new file")
+	}
+}
+
+func TestInotifyCloseStopsBlockedReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notifier, err := newFileNotifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = notifier.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overflow the events buffer without draining it so the reader blocks
+	for i := 0; i < 200; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file-%d", i))
+		if err = ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	notifier.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for readerRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("The inotify reader is still blocked after the notifier was closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRebuildChangesAreDiscarded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orchestra-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := &ProjectConfiguration{Name: "watch"}
+	service := ServiceInterfaces[TypeRunnableService].Create(map[string]interface{}{
+		"type":        TypeRunnableService,
+		"name":        "watched",
+		"working_dir": dir,
+		"commands":    []string{"true"},
+		"watch":       map[string]interface{}{"debounce_ms": 50, "rebuild": "touch built.txt"},
+	}, project).(*RunnableServiceConfiguration)
+
+	watcher, err := NewFileWatcher(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	watcher.handleChanges([]string{"main.go"})
+	if !service.AwaitExit() {
+		t.Fatal("Expected the restarted service to exit")
+	}
+
+	// The rebuild touching its output mustn't set off another round
+	select {
+	case event := <-watcher.Notifier.Events():
+		t.Errorf("Expected the changes from the rebuild to be discarded, got %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,8 @@
+// +build !linux
+
+package main
+
+// newFileNotifier is only supported on linux
+func newFileNotifier() (fileNotifier, error) {
+	return nil, ErrorWatchUnsupported
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -25,10 +26,11 @@ const (
 )
 
 var (
-	activeUsers    = make(map[*websocket.Conn]*User)
-	loggedInUsers  = NewRegistry() /* username -> *User */
-	processChannel = make(chan *RunnableServiceConfiguration)
-	upgrader       = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+	activeUsers      = make(map[*websocket.Conn]*User)
+	activeUsersMutex = &sync.RWMutex{}
+	loggedInUsers    = NewRegistry() /* username -> *User */
+	processChannel   = make(chan *RunnableServiceConfiguration)
+	upgrader         = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
 )
 
 // OutgoingSocketMessage a message to be sent to a socket
@@ -50,14 +52,18 @@ type IncomingSocketCommand struct {
 	ProjectID string        `json:"project_id"`
 	ServiceID string        `json:"service_id"`
 	Type      string        `json:"type"`
+
+	// User whoever sent the command. It's filled in from the socket the
+	// command came in on, never taken from the command itself
+	User string `json:"-"`
 }
 
 // ProjectUpdateMessage sends an update about a project
 type ProjectUpdateMessage struct {
-	ProjectID string                `json:"project_id"`
-	Project   *ProjectConfiguration `json:"project"`
-	Status    string                `json:"status"`
-	Type      string                `json:"type"`
+	ProjectID string                 `json:"project_id"`
+	Project   map[string]interface{} `json:"project"`
+	Status    string                 `json:"status"`
+	Type      string                 `json:"type"`
 }
 
 // ServiceStatusMessage a message to convey the current
@@ -69,6 +75,45 @@ type ServiceStatusMessage struct {
 	Type      string `json:"type"`
 }
 
+// ServiceAlertMessage a message to convey that one of the alert rules of a
+// particular service went off, and on what line
+type ServiceAlertMessage struct {
+	Alert     string `json:"alert"`
+	ID        string `json:"id"`
+	Line      string `json:"line"`
+	Name      string `json:"name"`
+	ProjectID string `json:"project_id"`
+	Type      string `json:"type"`
+}
+
+// ServiceHistoryMessage a message carrying some or all of the run history
+// of a particular service
+type ServiceHistoryMessage struct {
+	ID        string        `json:"id"`
+	ProjectID string        `json:"project_id"`
+	Runs      []*ServiceRun `json:"runs"`
+	Status    string        `json:"status"`
+	Type      string        `json:"type"`
+}
+
+// ServiceProcessesMessage a message carrying the process tree of a
+// particular service
+type ServiceProcessesMessage struct {
+	ID        string         `json:"id"`
+	ProjectID string         `json:"project_id"`
+	Processes []*ProcessNode `json:"processes"`
+	Type      string         `json:"type"`
+}
+
+// ServiceStatsMessage a message to convey the resource usage
+// of a particular service
+type ServiceStatsMessage struct {
+	ID        string        `json:"id"`
+	ProjectID string        `json:"project_id"`
+	Stats     *ProcessStats `json:"stats"`
+	Type      string        `json:"type"`
+}
+
 // ServiceLogMessage apsodfjkpsdofkpdsogjsdpoigjsdf
 type ServiceLogMessage struct {
 	Content   string `json:"content"`
@@ -106,7 +151,9 @@ func HandleWebsocket(rw http.ResponseWriter, req *http.Request) {
 	Debug.Println("Socket opened")
 
 	// user.Subscribe(Config.Projects["Ecco"])
+	activeUsersMutex.Lock()
 	activeUsers[ws] = user
+	activeUsersMutex.Unlock()
 	user.Socket = ws
 
 	done := make(chan struct{})
@@ -119,16 +166,17 @@ func HandleWebsocket(rw http.ResponseWriter, req *http.Request) {
 
 	// Socket is no longer active, remove it
 	Debug.Println("Socket closed")
+	activeUsersMutex.Lock()
 	delete(activeUsers, ws)
+	activeUsersMutex.Unlock()
 }
 
 // dumpExistingData dumps the current backlog of data when a new socket
 // comes online
 func dumpExistingData(user *User) {
 	for _, project := range Config.Projects {
-		for _, service := range project.Services {
-			genericService := service.(ServiceInterface)
-			if genericService.GetState() == ServiceRunning {
+		for _, genericService := range listServiceInstances(project) {
+			if IsActiveState(genericService.GetState()) {
 				user.WriteStatusMessage(genericService.GetState(), genericService)
 			}
 
@@ -144,20 +192,18 @@ func listenForProcessData() {
 	for {
 		select {
 		case src := <-processChannel:
-			for socket, user := range activeUsers {
-				if socket != nil {
-					user.WriteServiceData(src)
-				} else {
-					Error.Println("An active user somehow has a nil socket...")
-				}
-			}
+			broadcastProcessData(src)
 		}
 	}
 }
 
 // ForEachActiveUser performs the callback function on each currently active
-// user session
+// user session. Services call this from goroutines of their own, so the
+// sessions are locked against coming and going while it runs
 func ForEachActiveUser(action func(*User)) {
+	activeUsersMutex.RLock()
+	defer activeUsersMutex.RUnlock()
+
 	for socket, user := range activeUsers {
 		if socket != nil {
 			action(user)
@@ -166,27 +212,21 @@ func ForEachActiveUser(action func(*User)) {
 }
 
 func broadcastProcessData(src interface{}) {
-	for socket, user := range activeUsers {
-		if socket != nil {
-			user.WriteServiceData(src)
-		}
-	}
+	ForEachActiveUser(func(user *User) {
+		user.WriteServiceData(src)
+	})
 }
 
 func broadcastProjectUpdate(project *ProjectConfiguration) {
-	for socket, user := range activeUsers {
-		if socket != nil {
-			user.WriteProjectMessage(project)
-		}
-	}
+	ForEachActiveUser(func(user *User) {
+		user.WriteProjectMessage(project)
+	})
 }
 
 func broadcastProjectRemoval(project *ProjectConfiguration) {
-	for socket, user := range activeUsers {
-		if socket != nil {
-			user.WriteRemovalMessage(project)
-		}
-	}
+	ForEachActiveUser(func(user *User) {
+		user.WriteRemovalMessage(project)
+	})
 }
 
 // ping performs the basic ping logic to keep the websocket alive